@@ -0,0 +1,82 @@
+package gen
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// bytesOf converts the specified bytes or string value to a byte slice.
+func bytesOf(value interface{}) ([]byte, error) {
+	if b, ok := value.([]byte); ok {
+		return b, nil
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Kind() == reflect.String:
+		return []byte(v.String()), nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return v.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("expected bytes or string, got %T", value)
+	}
+}
+
+// base64Std encodes the specified bytes with padded standard base64.
+func base64Std(value interface{}) (string, error) {
+	b, err := bytesOf(value)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// base64URL encodes the specified bytes with unpadded URL-safe base64.
+func base64URL(value interface{}) (string, error) {
+	b, err := bytesOf(value)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hexString encodes the specified bytes as lower case hexadecimal digits.
+func hexString(value interface{}) (string, error) {
+	b, err := bytesOf(value)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// byteList renders the specified bytes as a comma separated list of
+// hexadecimal literals, e. g., "0x01, 0xff".
+func byteList(b []byte) string {
+	strs := make([]string, len(b))
+	for i, c := range b {
+		strs[i] = fmt.Sprintf("0x%02x", c)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// cArray renders the specified bytes as a C array initialiser, e. g.,
+// "{0x01, 0xff}".
+func cArray(value interface{}) (string, error) {
+	b, err := bytesOf(value)
+	if err != nil {
+		return "", err
+	}
+	return "{" + byteList(b) + "}", nil
+}
+
+// goBytes renders the specified bytes as a Go byte slice literal, e. g.,
+// "[]byte{0x01, 0xff}".
+func goBytes(value interface{}) (string, error) {
+	b, err := bytesOf(value)
+	if err != nil {
+		return "", err
+	}
+	return "[]byte{" + byteList(b) + "}", nil
+}
@@ -0,0 +1,364 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// toList converts the specified slice or array to a list.
+// A nil value is converted to an empty list.
+func toList(value interface{}) ([]interface{}, error) {
+	if value == nil {
+		return []interface{}{}, nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		return list, nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, v.Len())
+		for i := range result {
+			result[i] = v.Index(i).Interface()
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("expected list, got %T", value)
+	}
+}
+
+// sortedMapKeys returns the keys of the specified map value in sorted order.
+// Keys of messages starting with an underscore are omitted.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	vKeys := v.MapKeys()
+	if _, ok := v.Interface().(message); ok {
+		filtered := vKeys[:0]
+		for _, vkey := range vKeys {
+			if !strings.HasPrefix(vkey.String(), "_") {
+				filtered = append(filtered, vkey)
+			}
+		}
+		vKeys = filtered
+	}
+	sort.SliceStable(vKeys, func(i, j int) bool {
+		cmp, _ := compareValues(vKeys[i].Interface(), vKeys[j].Interface())
+		return cmp < 0
+	})
+	return vKeys
+}
+
+// fieldOf returns the value of the field with the specified dot separated
+// path within the given message, map or oneof. An empty path denotes the value
+// itself. Missing fields yield nil.
+func fieldOf(value interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return value, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		if value == nil {
+			return nil, nil
+		}
+		if oc, ok := value.(oneofCase); ok {
+			switch key {
+			case "Case":
+				value = oc.Case
+			case "Value":
+				value = oc.Value
+			default:
+				return nil, fmt.Errorf("oneof has no field '%s'", key)
+			}
+			continue
+		}
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot get field '%s' of %T", key, value)
+		}
+		elem := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		if !elem.IsValid() {
+			return nil, nil
+		}
+		value = elem.Interface()
+	}
+	return value, nil
+}
+
+// valuesEqual reports whether the specified values are equal. Numbers of
+// different types are compared by value.
+func valuesEqual(a, b interface{}) bool {
+	if cmp, err := compareValues(a, b); err == nil {
+		return cmp == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues compares the specified values. It returns a negative number
+// if a < b, zero if a == b, and a positive number if a > b. Numbers of
+// different types are compared by value, nil is less than everything else.
+// Strings, including enum values, are compared lexically, false is less than
+// true, and timestamps are compared chronologically.
+func compareValues(a, b interface{}) (int, error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+	if na, ok := toNumber(a); ok {
+		if nb, ok := toNumber(b); ok {
+			return na.compare(nb), nil
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				return -1, nil
+			case ta.After(tb):
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		return strings.Compare(va.String(), vb.String()), nil
+	case va.Kind() == reflect.Bool && vb.Kind() == reflect.Bool:
+		switch {
+		case va.Bool() == vb.Bool():
+			return 0, nil
+		case vb.Bool():
+			return -1, nil
+		default:
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+// list returns a list of the specified values.
+func list(values ...interface{}) []interface{} {
+	return append([]interface{}{}, values...)
+}
+
+// dict returns a map of the specified alternating keys and values.
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("odd number of arguments")
+	}
+	result := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("key %d is %T, not string", i/2, pairs[i])
+		}
+		result[key] = pairs[i+1]
+	}
+	return result, nil
+}
+
+// keys returns the sorted keys of the specified map or message.
+func keys(m interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return nil, fmt.Errorf("expected map, got %T", m)
+	}
+	vKeys := sortedMapKeys(v)
+	result := make([]interface{}, len(vKeys))
+	for i, vkey := range vKeys {
+		result[i] = vkey.Interface()
+	}
+	return result, nil
+}
+
+// values returns the values of the specified map or message, sorted by key.
+func values(m interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return nil, fmt.Errorf("expected map, got %T", m)
+	}
+	vKeys := sortedMapKeys(v)
+	result := make([]interface{}, len(vKeys))
+	for i, vkey := range vKeys {
+		result[i] = v.MapIndex(vkey).Interface()
+	}
+	return result, nil
+}
+
+// merge returns a new map with the entries of the specified maps or
+// messages. Entries of later maps override those of earlier ones.
+func merge(maps ...interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, m := range maps {
+		v := reflect.ValueOf(m)
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("expected map with string keys, got %T", m)
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = iter.Value().Interface()
+		}
+	}
+	return result, nil
+}
+
+// first returns the first element of the specified list, or nil if the list
+// is empty.
+func first(l interface{}) (interface{}, error) {
+	elems, err := toList(l)
+	if err != nil || len(elems) == 0 {
+		return nil, err
+	}
+	return elems[0], nil
+}
+
+// last returns the last element of the specified list, or nil if the list
+// is empty.
+func last(l interface{}) (interface{}, error) {
+	elems, err := toList(l)
+	if err != nil || len(elems) == 0 {
+		return nil, err
+	}
+	return elems[len(elems)-1], nil
+}
+
+// reverse returns the specified list in reverse order.
+func reverse(l interface{}) ([]interface{}, error) {
+	elems, err := toList(l)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		result[len(elems)-1-i] = elem
+	}
+	return result, nil
+}
+
+// chunk splits the specified list into chunks of the given size.
+// The last chunk may be shorter.
+func chunk(size int, l interface{}) ([][]interface{}, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", size)
+	}
+	elems, err := toList(l)
+	if err != nil {
+		return nil, err
+	}
+	var result [][]interface{}
+	for len(elems) > size {
+		result = append(result, elems[:size:size])
+		elems = elems[size:]
+	}
+	if len(elems) > 0 {
+		result = append(result, elems)
+	}
+	return result, nil
+}
+
+// uniq returns the specified list without duplicates, keeping the first
+// occurrence of each element.
+func uniq(l interface{}) ([]interface{}, error) {
+	elems, err := toList(l)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(elems))
+outer:
+	for _, elem := range elems {
+		for _, prev := range result {
+			if valuesEqual(elem, prev) {
+				continue outer
+			}
+		}
+		result = append(result, elem)
+	}
+	return result, nil
+}
+
+// pluck returns the values of the field with the specified path (see
+// fieldOf) of the elements of the given list.
+func pluck(path string, l interface{}) ([]interface{}, error) {
+	elems, err := toList(l)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		if result[i], err = fieldOf(elem, path); err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// where returns the elements of the specified list whose field with the
+// given path (see fieldOf) equals value.
+func where(path string, value interface{}, l interface{}) ([]interface{}, error) {
+	fields, err := pluck(path, l)
+	if err != nil {
+		return nil, err
+	}
+	elems, _ := toList(l)
+	var result []interface{}
+	for i, field := range fields {
+		if valuesEqual(field, value) {
+			result = append(result, elems[i])
+		}
+	}
+	return result, nil
+}
+
+// sortBy returns the specified list stably sorted by the field with the given
+// path (see fieldOf).
+func sortBy(path string, l interface{}) ([]interface{}, error) {
+	fields, err := pluck(path, l)
+	if err != nil {
+		return nil, err
+	}
+	elems, _ := toList(l)
+	indices := make([]int, len(elems))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var cmp int
+		cmp, err = compareValues(fields[indices[i]], fields[indices[j]])
+		return cmp < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(elems))
+	for i, idx := range indices {
+		result[i] = elems[idx]
+	}
+	return result, nil
+}
+
+// groupBy groups the elements of the specified list by the field with the
+// given path (see fieldOf). The map keys are the field values formatted as
+// strings. The elements of each group keep their order.
+func groupBy(path string, l interface{}) (map[string][]interface{}, error) {
+	fields, err := pluck(path, l)
+	if err != nil {
+		return nil, err
+	}
+	elems, _ := toList(l)
+	result := make(map[string][]interface{})
+	for i, field := range fields {
+		key := fmt.Sprint(field)
+		result[key] = append(result[key], elems[i])
+	}
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package gen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Supported data file formats.
+const (
+	dataFormatJSON      = "json"
+	dataFormatYAML      = "yaml"
+	dataFormatTextproto = "textproto"
+)
+
+// dataFormatsByExt maps file extensions to data file formats.
+var dataFormatsByExt = map[string]string{
+	".json":      dataFormatJSON,
+	".yaml":      dataFormatYAML,
+	".yml":       dataFormatYAML,
+	".textproto": dataFormatTextproto,
+	".txtpb":     dataFormatTextproto,
+	".pbtxt":     dataFormatTextproto,
+	".prototxt":  dataFormatTextproto,
+}
+
+// dataFile describes a data file, such as the extra data file.
+type dataFile struct {
+	// Path is the path to the data file. If empty, there is no data.
+	Path string
+
+	// Format is the explicit file format. If empty, the format is determined
+	// from the file extension.
+	Format string
+
+	// Type is the message type of a textproto file. If empty,
+	// google.protobuf.Struct is used.
+	Type protoreflect.FullName
+}
+
+// format returns the format of this data file. Files with an unknown or no
+// extension are read as JSON, as before formats were introduced.
+func (df *dataFile) format() (string, error) {
+	if df.Format != "" {
+		switch df.Format {
+		case dataFormatJSON, dataFormatYAML, dataFormatTextproto:
+			return df.Format, nil
+		default:
+			return "", fmt.Errorf("unsupported format '%s'", df.Format)
+		}
+	}
+	format, ok := dataFormatsByExt[strings.ToLower(filepath.Ext(df.Path))]
+	if !ok {
+		return dataFormatJSON, nil
+	}
+	return format, nil
+}
+
+// Validate validates this data file description.
+func (df *dataFile) Validate() error {
+	if df.Path == "" {
+		if df.Format != "" || df.Type != "" {
+			return errors.New("format or type given without file")
+		}
+		return nil
+	}
+	format, err := df.format()
+	if err != nil {
+		return err
+	}
+	if df.Type != "" {
+		if format != dataFormatTextproto {
+			return fmt.Errorf("type given for %s file", format)
+		}
+		if !df.Type.IsValid() {
+			return fmt.Errorf("invalid type name '%s'", df.Type)
+		}
+	}
+	return nil
+}
+
+// Load loads the data from this data file. If no file is
+// specified, Load returns (nil, nil). The message type of a textproto file is
+// looked up in the specified registry.
+func (df *dataFile) Load(r *registry) (map[string]interface{}, error) {
+	if df.Path == "" {
+		return nil, nil
+	}
+	format, err := df.format()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(df.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read data file '%s': %w", df.Path, err)
+	}
+	var result map[string]interface{}
+	switch format {
+	case dataFormatJSON:
+		result, err = decodeJSONData(data)
+	case dataFormatYAML:
+		result, err = decodeYAMLData(data)
+	case dataFormatTextproto:
+		result, err = r.decodeTextprotoData(data, df.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode data file '%s' (%s): %w",
+			df.Path, format, err)
+	}
+	return result, nil
+}
+
+// lineError describes an error at a specific line of an input file.
+type lineError struct {
+	// Line is the 1-based line number.
+	Line int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.Error.
+func (le *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", le.Line, le.Err)
+}
+
+// Unwrap returns the underlying error.
+func (le *lineError) Unwrap() error {
+	return le.Err
+}
+
+// lineAt returns the 1-based line number of the specified byte offset in data.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return 1 + strings.Count(string(data[:offset]), "\n")
+}
+
+// decodeJSONData decodes the specified JSON data.
+func decodeJSONData(data []byte) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := json.Unmarshal(data, &result)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return nil, &lineError{lineAt(data, syntaxErr.Offset), err}
+	case errors.As(err, &typeErr):
+		return nil, &lineError{lineAt(data, typeErr.Offset), err}
+	case err != nil:
+		return nil, err
+	}
+	return result, nil
+}
+
+// decodeYAMLData decodes the specified YAML data.
+func decodeYAMLData(data []byte) (map[string]interface{}, error) {
+	value, err := decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	switch x := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return x, nil
+	default:
+		return nil, &lineError{1, errors.New("top level is not a mapping")}
+	}
+}
+
+// prototextPosRE matches the position information in prototext errors.
+var prototextPosRE = regexp.MustCompile(`\(line (\d+):\d+\): `)
+
+// decodeTextprotoData decodes the specified textproto data as a
+// message of the specified type.
+func (r *registry) decodeTextprotoData(
+	data []byte, typeName protoreflect.FullName,
+) (map[string]interface{}, error) {
+	if typeName == "" {
+		var s structpb.Struct
+		if err := prototext.Unmarshal(data, &s); err != nil {
+			return nil, prototextError(err)
+		}
+		return s.AsMap(), nil
+	}
+	msgType, err := r.types.FindMessageByName(typeName)
+	if err != nil {
+		return nil, fmt.Errorf("find message type '%s': %w", typeName, err)
+	}
+	msg := msgType.New()
+	if err = (prototext.UnmarshalOptions{
+		Resolver: r.types,
+	}).Unmarshal(data, msg.Interface()); err != nil {
+		return nil, prototextError(err)
+	}
+	result, err := r.makeRawMessage(msg)
+	if err != nil {
+		return nil, fmt.Errorf("convert '%s' data: %w", typeName, err)
+	}
+	delete(result, origMsg)
+	return result, nil
+}
+
+// prototextError converts the specified prototext error into a lineError,
+// if possible.
+func prototextError(err error) error {
+	msg := err.Error()
+	loc := prototextPosRE.FindStringSubmatchIndex(msg)
+	if loc == nil {
+		return err
+	}
+	line, convErr := strconv.Atoi(msg[loc[2]:loc[3]])
+	if convErr != nil {
+		return err
+	}
+	return &lineError{line, errors.New(msg[loc[1]:])}
+}
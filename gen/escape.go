@@ -0,0 +1,269 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stringOf converts the specified template value to a string. Byte slices
+// are converted verbatim, everything else, e. g., enum values, is formatted
+// with fmt.Sprint.
+func stringOf(value interface{}) string {
+	switch x := value.(type) {
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// goQuote returns the specified value as a double quoted Go string literal.
+func goQuote(value interface{}) string {
+	return strconv.Quote(stringOf(value))
+}
+
+// cString returns the specified value as a double quoted C string literal.
+// Non-printable and non-ASCII bytes are written as octal escapes, so the
+// literal is valid regardless of the source and execution character sets.
+func cString(value interface{}) string {
+	s := stringOf(value)
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\v':
+			sb.WriteString(`\v`)
+		case '?':
+			// Avoid trigraphs.
+			if i > 0 && s[i-1] == '?' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// jsonString returns the specified value as a JSON string.
+func jsonString(value interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(stringOf(value)); err != nil {
+		return "", fmt.Errorf("encode JSON string: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// yamlString returns the specified value as a double quoted YAML scalar.
+func yamlString(value interface{}) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range stringOf(value) {
+		switch r {
+		case '"', '\\':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case 0:
+			sb.WriteString(`\0`)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\v':
+			sb.WriteString(`\v`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case 0x1b:
+			sb.WriteString(`\e`)
+		case 0x85:
+			sb.WriteString(`\N`)
+		case 0x2028:
+			sb.WriteString(`\L`)
+		case 0x2029:
+			sb.WriteString(`\P`)
+		default:
+			switch {
+			case r != 0xfeff && (r == ' ' || unicode.IsPrint(r)):
+				sb.WriteRune(r)
+			case r <= 0xff:
+				fmt.Fprintf(&sb, `\x%02X`, r)
+			case r <= 0xffff:
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			default:
+				fmt.Fprintf(&sb, `\U%08X`, r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// isShellSafe reports whether c needs no quoting in a POSIX shell word.
+func isShellSafe(c rune) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' ||
+		'0' <= c && c <= '9' || strings.ContainsRune("@%+=:,./_-", c)
+}
+
+// shellQuote quotes the specified value as a single POSIX shell word.
+// Values which need no quoting are returned unchanged.
+func shellQuote(value interface{}) string {
+	s := stringOf(value)
+	if s != "" && strings.IndexFunc(s, func(c rune) bool {
+		return !isShellSafe(c)
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sqlString returns the specified value as a single quoted standard SQL
+// string literal. Backslashes are not special in standard SQL.
+func sqlString(value interface{}) (string, error) {
+	s := stringOf(value)
+	if strings.IndexByte(s, 0) >= 0 {
+		return "", errors.New("SQL string cannot contain NUL characters")
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+}
+
+// xmlEscape escapes the specified value for use in XML character data and
+// attribute values.
+func xmlEscape(value interface{}) (string, error) {
+	var sb strings.Builder
+	if err := xml.EscapeText(&sb, []byte(stringOf(value))); err != nil {
+		return "", fmt.Errorf("escape XML: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// indent indents each non-empty line of the specified value by the given
+// number of spaces. Empty lines are left empty to avoid trailing whitespace.
+func indent(spaces int, value interface{}) (string, error) {
+	if spaces < 0 {
+		return "", fmt.Errorf("negative number of spaces %d", spaces)
+	}
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(stringOf(value), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// nindent is like indent but prepends a newline, so that a multi-line value
+// can start on a line of its own.
+func nindent(spaces int, value interface{}) (string, error) {
+	result, err := indent(spaces, value)
+	if err != nil {
+		return "", err
+	}
+	return "\n" + result, nil
+}
+
+// wrap breaks the lines of the specified value at spaces, so that lines are
+// at most width characters long. Words longer than width are not broken.
+// Existing line breaks are kept, and the leading whitespace of each line is
+// repeated on the lines it is broken into.
+func wrap(width int, value interface{}) string {
+	lines := strings.Split(stringOf(value), "\n")
+	for i, line := range lines {
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		leadLen := utf8.RuneCountInString(lead)
+		var sb strings.Builder
+		lineLen := 0
+		for _, word := range strings.Fields(line) {
+			wordLen := utf8.RuneCountInString(word)
+			switch {
+			case lineLen == 0:
+			case leadLen+lineLen+1+wordLen > width:
+				sb.WriteByte('\n')
+				lineLen = 0
+			default:
+				sb.WriteByte(' ')
+				lineLen++
+			}
+			if lineLen == 0 {
+				sb.WriteString(lead)
+			}
+			sb.WriteString(word)
+			lineLen += wordLen
+		}
+		lines[i] = sb.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimIndent removes leading and trailing blank lines from the specified
+// value, and the indentation common to all non-blank lines.
+func trimIndent(value interface{}) string {
+	lines := strings.Split(stringOf(value), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var common string
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if i == 0 {
+			common = lead
+		} else {
+			common = common[:commonPrefixLen(common, lead)]
+		}
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = line[len(common):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,38 @@
+package gen
+
+import "testing"
+
+func TestIndent(t *testing.T) {
+	got, err := nindent(2, "a\n\nb")
+	if err != nil {
+		t.Fatalf("nindent: %v", err)
+	}
+	if want := "\n  a\n\n  b"; got != want {
+		t.Errorf("nindent(2, ...) = %q, want %q", got, want)
+	}
+	if _, err := indent(-1, "a"); err == nil {
+		t.Error("indent with negative spaces succeeded")
+	}
+	if _, err := nindent(-1, "a"); err == nil {
+		t.Error("nindent with negative spaces succeeded")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		width int
+		in    string
+		want  string
+	}{
+		{10, "one two three four", "one two\nthree four"},
+		{10, "  indented text here", "  indented\n  text\n  here"},
+		{10, "a b\n\tc d e f g", "a b\n\tc d e f g"},
+		{3, "longword x", "longword\nx"},
+	}
+	for _, test := range tests {
+		if got := wrap(test.width, test.in); got != test.want {
+			t.Errorf("wrap(%d, %q) = %q, want %q",
+				test.width, test.in, got, test.want)
+		}
+	}
+}
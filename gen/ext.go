@@ -4,15 +4,14 @@ import (
 	"fmt"
 
 	"google.golang.org/protobuf/reflect/protoreflect"
-	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 // getExtensions obtains the extension types for the option to provide the data.
 // It also returns an empty data message.
-func getExtensions(options options) (
+func (r *registry) getExtensions(options options) (
 	msgxt protoreflect.ExtensionType, data protoreflect.Message, err error,
 ) {
-	msgxt, err = protoregistry.GlobalTypes.FindExtensionByName(
+	msgxt, err = r.types.FindExtensionByName(
 		options.Message.OptionFieldName)
 	if err != nil {
 		return nil, nil, fmt.Errorf("find extension '%s': %w",
@@ -30,7 +29,7 @@ func getExtensions(options options) (
 		return nil, nil, fmt.Errorf("get subdescriptor: %w", err)
 	}
 	dataType, err :=
-		protoregistry.GlobalTypes.FindMessageByName(subDesc.FullName())
+		r.types.FindMessageByName(subDesc.FullName())
 	if err != nil {
 		return nil, nil, fmt.Errorf("find data type: %w", err)
 	}
@@ -39,13 +38,20 @@ func getExtensions(options options) (
 }
 
 // getSubDescriptor returns the message descriptor of the subfield of the
-// specified field descriptor determined by subfields.
+// specified field descriptor determined by subfields. Groups count as
+// messages. Repeated fields are allowed, their elements are merged, but map
+// fields are not.
 func getSubDescriptor(
 	fieldDesc protoreflect.FieldDescriptor, subfields []protoreflect.Name,
 ) (protoreflect.MessageDescriptor, error) {
-	if fieldDesc.Kind() != protoreflect.MessageKind {
+	switch fieldDesc.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+	default:
 		return nil, fmt.Errorf("field '%s' is not a message", fieldDesc.FullName())
 	}
+	if fieldDesc.IsMap() {
+		return nil, fmt.Errorf("field '%s' is a map", fieldDesc.FullName())
+	}
 	msgDesc := fieldDesc.Message()
 	if len(subfields) == 0 {
 		return msgDesc, nil
@@ -0,0 +1,122 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// has reports whether the named field of the specified message is populated,
+// following protobuf presence semantics: fields with explicit presence, e. g.,
+// proto3 optional fields, are populated if they have been set, even to their
+// default value; other scalar fields are populated if they are non-zero, and
+// repeated fields if they are non-empty. A oneof is populated if one of its
+// fields is set. Extension fields are named by their bracketed full name,
+// e. g., "[pkg.ext]". The message can be a raw message, a protobuf message or a
+// map. For maps without an original protobuf message, e. g., extra data, and
+// for names of raw messages which are neither a field nor a oneof, e. g.,
+// merged extra keys, has reports whether the key exists.
+func (r *registry) has(msg interface{}, name string) (bool, error) {
+	var pm proto.Message
+	raw, isRaw := msg.(message)
+	switch x := msg.(type) {
+	case nil:
+		return false, nil
+	case message:
+		pm, _ = x[origMsg].(proto.Message)
+	case proto.Message:
+		pm = x
+	}
+	if pm == nil {
+		v := reflect.ValueOf(msg)
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return false, fmt.Errorf("expected message or map, got %T", msg)
+		}
+		return v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())).IsValid(),
+			nil
+	}
+	m := pm.ProtoReflect()
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		xt, err := r.types.FindExtensionByName(
+			protoreflect.FullName(name[1 : len(name)-1]))
+		if err != nil {
+			return false, fmt.Errorf("find extension '%s': %w", name, err)
+		}
+		return m.Has(xt.TypeDescriptor()), nil
+	}
+	if od := m.Descriptor().Oneofs().ByName(protoreflect.Name(name)); od != nil {
+		return m.WhichOneof(od) != nil, nil
+	}
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil && isRaw {
+		_, ok := raw[name]
+		return ok, nil
+	}
+	if fd == nil {
+		return false, fmt.Errorf("message '%s' has no field '%s'",
+			m.Descriptor().FullName(), name)
+	}
+	return m.Has(fd), nil
+}
+
+// fieldEntry describes a field of a raw message.
+type fieldEntry struct {
+	// Name is the field name.
+	Name string
+
+	// Value is the raw field value.
+	Value interface{}
+
+	// Desc is the field descriptor.
+	Desc protoreflect.FieldDescriptor
+}
+
+// fields returns the fields of the specified raw message in declaration
+// order, followed by the populated extension fields in field number order.
+// Unset fields of oneofs are omitted, like in the raw message.
+func fields(msg message) ([]fieldEntry, error) {
+	pm, ok := msg[origMsg].(proto.Message)
+	if !ok {
+		return nil, errors.New("message does not represent a protobuf message")
+	}
+	fds := pm.ProtoReflect().Descriptor().Fields()
+	result := make([]fieldEntry, 0, fds.Len())
+	for i := 0; i != fds.Len(); i++ {
+		fd := fds.Get(i)
+		value, ok := msg[string(fd.Name())]
+		if !ok {
+			continue
+		}
+		result = append(result, fieldEntry{string(fd.Name()), value, fd})
+	}
+	var xfields []fieldEntry
+	pm.ProtoReflect().Range(
+		func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			if fd.IsExtension() {
+				key := extensionKey(fd)
+				xfields = append(xfields, fieldEntry{key, msg[key], fd})
+			}
+			return true
+		})
+	sort.Slice(xfields, func(i, j int) bool {
+		return xfields[i].Desc.Number() < xfields[j].Desc.Number()
+	})
+	return append(result, xfields...), nil
+}
+
+// fieldsByNumber is like fields but returns the fields in field number order.
+func fieldsByNumber(msg message) ([]fieldEntry, error) {
+	result, err := fields(msg)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Desc.Number() < result[j].Desc.Number()
+	})
+	return result, nil
+}
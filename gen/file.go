@@ -3,9 +3,7 @@ package gen
 import (
 	"errors"
 	"fmt"
-	"path/filepath"
 	"strings"
-	"text/template"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
@@ -27,35 +25,64 @@ func File(req *pluginpb.CodeGeneratorRequest) (
 	if err != nil {
 		return nil, err
 	}
-	tpl, err := loadTemplate(params.TemplatePath)
+	tpl, err := loadTemplate(params)
 	if err != nil {
 		return nil, err
 	}
-	if err := registerFiles(req.GetProtoFile()); err != nil {
-		return nil, err
-	}
+	r, err := newRegistry(req.GetProtoFile())
 	if err != nil {
 		return nil, fmt.Errorf("register proto files: %w", err)
 	}
-	msgxt, data, err := getExtensions(params.Options)
+	msgxt, data, err := r.getExtensions(params.Options)
 	if err != nil {
 		return nil, fmt.Errorf("get extension types: %w", err)
 	}
-	if err = mergeData(
+	if err = r.mergeData(
 		data, msgxt, params.Options.Message.Subfields,
 	); err != nil {
 		return nil, err
 	}
-	rawData := makeRawMessage(data)
-	for key, value := range params.Extra {
+	if params.UnknownFields == unknownStrict {
+		if err = checkUnknown(data); err != nil {
+			return nil, fmt.Errorf("unknown fields in option data: %w", err)
+		}
+	}
+	extra, err := params.Extra.Load(r)
+	if err != nil {
+		return nil, fmt.Errorf("load extra data: %w", err)
+	}
+	rawData, err := r.makeRawMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("convert option data: %w", err)
+	}
+	for key, value := range extra {
 		if rawData[key] != nil {
 			return nil,
 				fmt.Errorf("extra data key '%s' already present in proto data", key)
 		}
 		rawData[key] = value
 	}
+	if params.Vars != nil {
+		if rawData[varsKey] != nil {
+			return nil, fmt.Errorf("variables key '%s' already present in data",
+				varsKey)
+		}
+		rawData[varsKey] = params.Vars
+	}
+	overrides, err := params.TypeMap.Load(r)
+	if err != nil {
+		return nil, fmt.Errorf("load type map: %w", err)
+	}
+	typeMaps, err := makeTypeMaps(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("make type maps: %w", err)
+	}
 	var sb strings.Builder
-	if err = tpl.Execute(&sb, rawData); err != nil {
+	if err = tpl.Execute(&sb, params.Header, rawData, &execState{
+		goImportPath: params.GoImportPath,
+		registry:     r,
+		typeMaps:     typeMaps,
+	}); err != nil {
 		return nil, fmt.Errorf("execute template: %w", err)
 	}
 	return &pluginpb.CodeGeneratorResponse_File{
@@ -64,81 +91,64 @@ func File(req *pluginpb.CodeGeneratorRequest) (
 	}, nil
 }
 
-// loadTemplate loads the template definition from the specified files.
-func loadTemplate(glob string) (*template.Template, error) {
-	// We need to execute the glob manually because the template package needs
-	// an explicit file name for template name.
-	files, err := filepath.Glob(glob)
+// registry provides the descriptors and types of a code generator request.
+// It is kept apart from the global registries, which contain the files
+// compiled into the plugin, e. g., descriptor.proto, so that the request is
+// the single source of truth, and so that requests do not interfere with
+// each other.
+type registry struct {
+	// files contains the files of the request.
+	files *protoregistry.Files
+
+	// types contains the dynamic types of the messages, enums and extensions
+	// declared in files.
+	types *protoregistry.Types
+}
+
+// newRegistry creates a registry from the specified proto files, which must
+// include all their dependencies.
+func newRegistry(fdpbs []*descriptorpb.FileDescriptorProto) (*registry, error) {
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{
+		File: fdpbs,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("glob: %w", err)
+		return nil, fmt.Errorf("create file descriptors: %w", err)
 	}
-	if len(files) == 0 {
-		return nil, fmt.Errorf("no matching files: %s", glob)
+	r := &registry{
+		files: files,
+		types: new(protoregistry.Types),
 	}
-	tpl, err := template.New(filepath.Base(files[0])).
-		Funcs(template.FuncMap{
-			"setglob": setglob,
-			"getglob": getglob,
-			"delglob": delglob,
-		}).ParseFiles(files...)
-	if err != nil {
-		return nil, fmt.Errorf("parse template pattern '%s': %w", glob, err)
-	}
-	return tpl, nil
-}
-
-// registerFiles registers the specified proto files with the global registry.
-func registerFiles(fdpbs []*descriptorpb.FileDescriptorProto) error {
 	for _, fdpb := range fdpbs {
-		fd, err := protodesc.NewFile(fdpb, protoregistry.GlobalFiles)
+		fd, err := files.FindFileByPath(fdpb.GetName())
 		if err != nil {
-			return fmt.Errorf("create file descriptor for '%s': %w",
-				fdpb.GetName(), err)
+			return nil, fmt.Errorf("find file '%s': %w", fdpb.GetName(), err)
 		}
-		_, err = protoregistry.GlobalFiles.FindFileByPath(fd.Path())
-		if err == nil {
-			continue
-		}
-		if err != protoregistry.NotFound {
-			return fmt.Errorf("looking up file '%s': %w", fd.Path(), err)
-		}
-		if err = protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
-			return fmt.Errorf("register file '%s': %w", fd.Path(), err)
-		}
-		if err = registerTypesFromFile(fd); err != nil {
-			return fmt.Errorf("register types for '%s': %w", fd.Path(), err)
+		if err = r.registerTypesFromFile(fd); err != nil {
+			return nil, fmt.Errorf("register types for '%s': %w", fd.Path(), err)
 		}
 	}
-	return nil
+	return r, nil
 }
 
 // registerTypesFromFile registers the types from the specified file.
-func registerTypesFromFile(fd protoreflect.FileDescriptor) error {
-	if err := registerEnums(fd.Enums()); err != nil {
+func (r *registry) registerTypesFromFile(fd protoreflect.FileDescriptor) error {
+	if err := r.registerEnums(fd.Enums()); err != nil {
 		return fmt.Errorf("register enums: %w", err)
 	}
-	if err := registerMessages(fd.Messages()); err != nil {
+	if err := r.registerMessages(fd.Messages()); err != nil {
 		return fmt.Errorf("register messages: %w", err)
 	}
-	if err := registerExtensions(fd.Extensions()); err != nil {
+	if err := r.registerExtensions(fd.Extensions()); err != nil {
 		return fmt.Errorf("register extensions: %w", err)
 	}
 	return nil
 }
 
 // registerEnums registers the specified enums.
-func registerEnums(eds protoreflect.EnumDescriptors) error {
+func (r *registry) registerEnums(eds protoreflect.EnumDescriptors) error {
 	for i := 0; i != eds.Len(); i++ {
 		ed := eds.Get(i)
-		_, err := protoregistry.GlobalTypes.FindEnumByName(ed.FullName())
-		if err == nil {
-			continue
-		}
-		if err != protoregistry.NotFound {
-			return fmt.Errorf("find enum '%s': %w", ed.FullName(), err)
-		}
-		et := dynamicpb.NewEnumType(ed)
-		if err = protoregistry.GlobalTypes.RegisterEnum(et); err != nil {
+		if err := r.types.RegisterEnum(dynamicpb.NewEnumType(ed)); err != nil {
 			return fmt.Errorf("register enum '%s': %w", ed.FullName(), err)
 		}
 	}
@@ -146,29 +156,22 @@ func registerEnums(eds protoreflect.EnumDescriptors) error {
 }
 
 // registerMessages registers the specified messages.
-func registerMessages(mds protoreflect.MessageDescriptors) error {
+func (r *registry) registerMessages(mds protoreflect.MessageDescriptors) error {
 	for i := 0; i != mds.Len(); i++ {
 		md := mds.Get(i)
-		if err := registerEnums(md.Enums()); err != nil {
+		if err := r.registerEnums(md.Enums()); err != nil {
 			return fmt.Errorf("register message '%s' enums: %w", md.FullName(), err)
 		}
-		if err := registerMessages(md.Messages()); err != nil {
+		if err := r.registerMessages(md.Messages()); err != nil {
 			return fmt.Errorf("register message '%s' messages: %w",
 				md.FullName(), err)
 		}
-		if err := registerExtensions(md.Extensions()); err != nil {
+		if err := r.registerExtensions(md.Extensions()); err != nil {
 			return fmt.Errorf("register message '%s' extensions: %w",
 				md.FullName(), err)
 		}
-		_, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName())
-		if err == nil {
-			continue
-		}
-		if err != protoregistry.NotFound {
-			return fmt.Errorf("find message '%s': %w", md.FullName(), err)
-		}
-		mt := dynamicpb.NewMessageType(md)
-		if err = protoregistry.GlobalTypes.RegisterMessage(mt); err != nil {
+		err := r.types.RegisterMessage(dynamicpb.NewMessageType(md))
+		if err != nil {
 			return fmt.Errorf("register message '%s': %w", md.FullName(), err)
 		}
 	}
@@ -176,18 +179,13 @@ func registerMessages(mds protoreflect.MessageDescriptors) error {
 }
 
 // registerExtensions registers the specified extensions.
-func registerExtensions(xds protoreflect.ExtensionDescriptors) error {
+func (r *registry) registerExtensions(
+	xds protoreflect.ExtensionDescriptors,
+) error {
 	for i := 0; i != xds.Len(); i++ {
 		xd := xds.Get(i)
-		_, err := protoregistry.GlobalTypes.FindExtensionByName(xd.FullName())
-		if err == nil {
-			continue
-		}
-		if err != protoregistry.NotFound {
-			return fmt.Errorf("find extension '%s': %w", xd.FullName(), err)
-		}
-		xt := dynamicpb.NewExtensionType(xd)
-		if err = protoregistry.GlobalTypes.RegisterExtension(xt); err != nil {
+		err := r.types.RegisterExtension(dynamicpb.NewExtensionType(xd))
+		if err != nil {
 			return fmt.Errorf("register extension '%s': %w", xd.FullName(), err)
 		}
 	}
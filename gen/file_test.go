@@ -0,0 +1,111 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// fileTestRequest returns a code generator request rendering the specified
+// template with the acme.data option of a message in acme/use.proto as data.
+// The option is of type acme.Data, which has a single field with the given
+// name and type. The field is set to the specified wire format value.
+func fileTestRequest(
+	tpl, name string, typ descriptorpb.FieldDescriptorProto_Type,
+	value []byte,
+) *pluginpb.CodeGeneratorRequest {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	wireType := protowire.BytesType
+	if typ != descriptorpb.FieldDescriptorProto_TYPE_STRING {
+		wireType = protowire.VarintType
+	}
+	data := append(protowire.AppendTag(nil, 1, wireType), value...)
+	opts := &descriptorpb.MessageOptions{}
+	opts.ProtoReflect().SetUnknown(protowire.AppendBytes(
+		protowire.AppendTag(nil, 50000, protowire.BytesType), data))
+	return &pluginpb.CodeGeneratorRequest{
+		Parameter: proto.String("template=" + tpl + ",msgopt=acme.data,out=out"),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(
+				descriptorpb.File_google_protobuf_descriptor_proto),
+			{
+				Name:       proto.String("acme/data.proto"),
+				Package:    proto.String("acme"),
+				Dependency: []string{"google/protobuf/descriptor.proto"},
+				MessageType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("Data"),
+					Field: []*descriptorpb.FieldDescriptorProto{{
+						Name: proto.String(name), Number: proto.Int32(1),
+						Label: optional, Type: typ.Enum(),
+					}},
+				}},
+				Extension: []*descriptorpb.FieldDescriptorProto{{
+					Name: proto.String("data"), Number: proto.Int32(50000),
+					Label:    optional,
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".acme.Data"),
+					Extendee: proto.String(".google.protobuf.MessageOptions"),
+				}},
+			},
+			{
+				Name:       proto.String("acme/use.proto"),
+				Package:    proto.String("acme"),
+				Dependency: []string{"acme/data.proto"},
+				MessageType: []*descriptorpb.DescriptorProto{{
+					Name:    proto.String("Use"),
+					Options: opts,
+				}},
+			},
+		},
+	}
+}
+
+// TestFileIsolation checks that requests declaring the same message with
+// different fields do not interfere, neither in turn nor concurrently.
+func TestFileIsolation(t *testing.T) {
+	tpl := filepath.Join(t.TempDir(), "data.tpl")
+	if err := os.WriteFile(tpl, []byte(`{{ toJSON . }}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		req  *pluginpb.CodeGeneratorRequest
+		want string
+	}{
+		{fileTestRequest(tpl, "name",
+			descriptorpb.FieldDescriptorProto_TYPE_STRING,
+			protowire.AppendString(nil, "foo")), `{"name":"foo"}`},
+		{fileTestRequest(tpl, "count",
+			descriptorpb.FieldDescriptorProto_TYPE_INT32,
+			protowire.AppendVarint(nil, 42)), `{"count":42}`},
+	}
+	check := func(req *pluginpb.CodeGeneratorRequest, want string) {
+		file, err := File(req)
+		if err != nil {
+			t.Errorf("File: %v", err)
+			return
+		}
+		if got := file.GetContent(); got != want {
+			t.Errorf("File content = %s, want %s", got, want)
+		}
+	}
+	for _, test := range tests {
+		check(test.req, test.want)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i != 8; i++ {
+		test := tests[i%len(tests)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check(test.req, test.want)
+		}()
+	}
+	wg.Wait()
+}
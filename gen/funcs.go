@@ -2,44 +2,270 @@ package gen
 
 import (
 	"fmt"
-	"sync"
+	"text/template"
 )
 
-// globals holds global variables for templates.
-// Normal template variables are not inherited by nested templates.
-// The globals mechanism with the setglob, getglob, and delglob functions
-// circumvents this issue.
-var globals = make(map[string]interface{})
+// execState holds the state of a single template execution.
+type execState struct {
+	// tpl is the template being executed.
+	tpl *template.Template
 
-// globalsMtx protects globals against concurrent access.
-var globalsMtx sync.Mutex
+	// depth is the current nesting depth of include and tpl calls.
+	depth int
+
+	// globals holds the global variables of this execution.
+	// Normal template variables are not inherited by nested templates.
+	// The setglob, getglob, etc. functions circumvent this issue.
+	globals map[string]interface{}
+
+	// goImportPath is the import path of the generated Go package.
+	goImportPath string
+
+	// goImports maps the import paths of the Go packages registered for the
+	// goImports block to their package names.
+	goImports map[string]string
+
+	// requirements maps requirement kinds to the sets of values recorded
+	// with the require function.
+	requirements map[string]map[string]bool
+
+	// registry provides the descriptors and types of the code generator
+	// request.
+	registry *registry
+
+	// typeMaps contains the type maps for the mapType and mapValueType
+	// functions.
+	typeMaps typeMaps
+}
+
+// templateFuncs returns the template functions bound to the specified
+// execution state.
+func templateFuncs(s *execState) template.FuncMap {
+	return template.FuncMap{
+		"setglob":    s.setglob,
+		"getglob":    s.getglob,
+		"hasglob":    s.hasglob,
+		"delglob":    s.delglob,
+		"defglob":    s.defglob,
+		"appendglob": s.appendglob,
+		"incglob":    s.incglob,
+		"putglob":    s.putglob,
+
+		"include": s.include,
+		"tpl":     s.renderTpl,
+
+		"upperCamel":     upperCamel,
+		"lowerCamel":     lowerCamel,
+		"snake":          snake,
+		"kebab":          kebab,
+		"screamingSnake": screamingSnake,
+		"initialisms":    applyInitialisms,
+		"words":          splitWords,
+		"plural":         plural,
+		"singular":       singular,
+
+		"goName":             s.registry.goName,
+		"goImportPath":       s.registry.goImportPath,
+		"goPackageName":      s.registry.goPackageName,
+		"javaName":           s.registry.javaName,
+		"javaFullName":       s.registry.javaFullName,
+		"javaPackage":        s.registry.javaPackage,
+		"javaOuterClassname": s.registry.javaOuterClassname,
+		"csharpName":         s.registry.csharpName,
+		"csharpFullName":     s.registry.csharpFullName,
+		"csharpNamespace":    s.registry.csharpNamespace,
+		"tsName":             s.registry.tsName,
+
+		"list":    list,
+		"dict":    dict,
+		"keys":    keys,
+		"values":  values,
+		"merge":   merge,
+		"first":   first,
+		"last":    last,
+		"reverse": reverse,
+		"chunk":   chunk,
+		"uniq":    uniq,
+		"pluck":   pluck,
+		"where":   where,
+		"sortBy":  sortBy,
+		"groupBy": groupBy,
+
+		"add":     add,
+		"sub":     sub,
+		"mul":     mul,
+		"div":     div,
+		"mod":     mod,
+		"max":     maxNumber,
+		"min":     minNumber,
+		"seq":     seq,
+		"compare": compare,
+		"toInt":   toInt,
+		"toFloat": toFloat,
+
+		"goQuote":    goQuote,
+		"cString":    cString,
+		"jsonString": jsonString,
+		"yamlString": yamlString,
+		"shellQuote": shellQuote,
+		"sqlString":  sqlString,
+		"xmlEscape":  xmlEscape,
+		"indent":     indent,
+		"nindent":    nindent,
+		"wrap":       wrap,
+		"trimIndent": trimIndent,
+
+		"toJSON":       s.registry.toJSON,
+		"toPrettyJSON": s.registry.toPrettyJSON,
+		"toYAML":       s.registry.toYAML,
+		"toTextproto":  s.registry.toTextproto,
+
+		"base64":    base64Std,
+		"base64url": base64URL,
+		"hex":       hexString,
+		"cArray":    cArray,
+		"goBytes":   goBytes,
+
+		"goImport":         s.goImport,
+		"qualifiedGoIdent": s.qualifiedGoIdent,
+		"goImports":        s.goImportsBlock,
+		"require":          s.require,
+		"required":         s.required,
+
+		"formatTime": formatTime,
+		"unix":       unix,
+		"seconds":    seconds,
+
+		"has":            s.registry.has,
+		"fields":         fields,
+		"fieldsByNumber": fieldsByNumber,
+
+		"mapType":      s.mapType,
+		"mapValueType": s.typeMaps.mapValueType,
+	}
+}
+
+// global returns the named global variable and whether it exists.
+func (s *execState) global(name string) (interface{}, bool) {
+	value, ok := s.globals[name]
+	return value, ok
+}
+
+// setGlobal sets the named global variable to the specified value.
+func (s *execState) setGlobal(name string, value interface{}) {
+	if s.globals == nil {
+		s.globals = make(map[string]interface{})
+	}
+	s.globals[name] = value
+}
 
 // setglob sets the specified named global variable to the given value.
 // It always returns the empty string.
-func setglob(name string, value interface{}) string {
-	globalsMtx.Lock()
-	defer globalsMtx.Unlock()
-	globals[name] = value
+func (s *execState) setglob(name string, value interface{}) string {
+	s.setGlobal(name, value)
 	return ""
 }
 
 // getglob obtains the value of the named variable.
-func getglob(name string) (interface{}, error) {
-	globalsMtx.Lock()
-	defer globalsMtx.Unlock()
-	value, ok := globals[name]
+func (s *execState) getglob(name string) (interface{}, error) {
+	value, ok := s.global(name)
 	if !ok {
 		return nil, fmt.Errorf("no such global variable: %s", name)
 	}
 	return value, nil
 }
 
+// hasglob reports whether the named global variable exists.
+func (s *execState) hasglob(name string) bool {
+	_, ok := s.global(name)
+	return ok
+}
+
 // delglob unsets the named global variable.
 // If the variable does not exist, no operation is performed.
 // It always returns the empty string.
-func delglob(name string) string {
-	globalsMtx.Lock()
-	defer globalsMtx.Unlock()
-	delete(globals, name)
+func (s *execState) delglob(name string) string {
+	delete(s.globals, name)
+	return ""
+}
+
+// defglob sets the named global variable to the specified value unless the
+// variable already exists. It always returns the empty string.
+func (s *execState) defglob(name string, value interface{}) string {
+	if _, ok := s.global(name); !ok {
+		s.setGlobal(name, value)
+	}
 	return ""
 }
+
+// appendglob appends the specified value to the list in the named global
+// variable. If the variable does not exist, a new list is created.
+// It always returns the empty string.
+func (s *execState) appendglob(name string, value interface{}) (string, error) {
+	var elems []interface{}
+	if prev, ok := s.global(name); ok {
+		var err error
+		if elems, err = toList(prev); err != nil {
+			return "", fmt.Errorf("global variable '%s': %w", name, err)
+		}
+	}
+	s.setGlobal(name, append(elems[:len(elems):len(elems)], value))
+	return "", nil
+}
+
+// incglob increments the counter in the named global variable by one, or by
+// the specified delta. If the variable does not exist, the counter starts at
+// zero. It always returns the empty string.
+func (s *execState) incglob(name string, delta ...interface{}) (string, error) {
+	var by interface{} = 1
+	switch len(delta) {
+	case 0:
+	case 1:
+		by = delta[0]
+	default:
+		return "", fmt.Errorf("expected at most one delta, got %d", len(delta))
+	}
+	var counter interface{} = 0
+	if prev, ok := s.global(name); ok {
+		counter = prev
+	}
+	result, err := add(counter, by)
+	if err != nil {
+		return "", fmt.Errorf("global variable '%s': %w", name, err)
+	}
+	s.setGlobal(name, result)
+	return "", nil
+}
+
+// putglob puts the specified key and value into the map in the named global
+// variable. If the variable does not exist, a new map is created. The map is
+// copied rather than modified in place, since it may be shared with template
+// data. Putting into a raw message drops its original protobuf message, which
+// no longer matches. It always returns the empty string.
+func (s *execState) putglob(name, key string, value interface{}) (string, error) {
+	prev, ok := s.global(name)
+	if !ok {
+		prev = map[string]interface{}{}
+	}
+	var src map[string]interface{}
+	switch x := prev.(type) {
+	case map[string]interface{}:
+		src = x
+	case message:
+		src = x
+	default:
+		return "", fmt.Errorf("global variable '%s' is %T, not a map", name, prev)
+	}
+	m := make(map[string]interface{}, len(src)+1)
+	for k, v := range src {
+		m[k] = v
+	}
+	m[key] = value
+	if _, ok := prev.(message); ok {
+		delete(m, origMsg)
+		s.setGlobal(name, message(m))
+	} else {
+		s.setGlobal(name, m)
+	}
+	return "", nil
+}
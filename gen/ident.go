@@ -0,0 +1,557 @@
+package gen
+
+import (
+	"fmt"
+	"go/token"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// resolveDescriptor resolves the specified descriptor reference. The
+// reference can be a descriptor, a raw message, the path of a proto file, or
+// a fully qualified name. Paths and names are looked up in the files of the
+// code generator request. Enum values can be named relative to their enum
+// type, e. g., "pkg.Enum.VALUE", in addition to the protobuf scoping rules.
+func (r *registry) resolveDescriptor(
+	ref interface{},
+) (protoreflect.Descriptor, error) {
+	switch x := ref.(type) {
+	case protoreflect.Descriptor:
+		return x, nil
+	case message:
+		if orig, ok := x[origMsg].(proto.Message); ok {
+			return orig.ProtoReflect().Descriptor(), nil
+		}
+		return nil, fmt.Errorf("message without %s key", origMsg)
+	case string:
+		if strings.HasSuffix(x, ".proto") {
+			fd, err := r.files.FindFileByPath(x)
+			if err != nil {
+				return nil, fmt.Errorf("find file '%s': %w", x, err)
+			}
+			return fd, nil
+		}
+		name := protoreflect.FullName(strings.TrimPrefix(x, "."))
+		desc, err := r.files.FindDescriptorByName(name)
+		if err == nil {
+			return desc, nil
+		}
+		parent, parentErr :=
+			r.files.FindDescriptorByName(name.Parent())
+		if parentErr == nil {
+			if ed, ok := parent.(protoreflect.EnumDescriptor); ok {
+				if evd := ed.Values().ByName(name.Name()); evd != nil {
+					return evd, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("find descriptor '%s': %w", name, err)
+	default:
+		return nil, fmt.Errorf("unable to resolve descriptor from %T", ref)
+	}
+}
+
+// resolveFile resolves the specified descriptor reference to the file
+// containing the descriptor.
+func (r *registry) resolveFile(
+	ref interface{},
+) (protoreflect.FileDescriptor, error) {
+	desc, err := r.resolveDescriptor(ref)
+	if err != nil {
+		return nil, err
+	}
+	return desc.ParentFile(), nil
+}
+
+// fileOptions returns the options of the specified file.
+func fileOptions(fd protoreflect.FileDescriptor) *descriptorpb.FileOptions {
+	opts, _ := fd.Options().(*descriptorpb.FileOptions)
+	if opts == nil {
+		return &descriptorpb.FileOptions{}
+	}
+	return opts
+}
+
+// localName returns the name of the specified descriptor relative to the
+// package of its file, e. g., "Outer.Inner" for a nested message.
+func localName(desc protoreflect.Descriptor) string {
+	pkg := desc.ParentFile().Package()
+	if pkg == "" {
+		return string(desc.FullName())
+	}
+	return strings.TrimPrefix(string(desc.FullName()), string(pkg)+".")
+}
+
+// goSanitized converts the specified string to a valid Go identifier
+// following the rules of protoc-gen-go.
+func goSanitized(s string) string {
+	// Sanitize the input to the set of valid characters,
+	// which must be '_' or be in the Unicode L or N categories.
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+	// Prepend '_' in the event of a Go keyword conflict or if
+	// the identifier is invalid (does not start in the Unicode L category).
+	r, _ := utf8.DecodeRuneInString(s)
+	if token.Lookup(s).IsKeyword() || !unicode.IsLetter(r) {
+		return "_" + s
+	}
+	return s
+}
+
+// goImportPath returns the Go import path of the file containing the
+// specified descriptor, as determined by the go_package option.
+func (r *registry) goImportPath(ref interface{}) (string, error) {
+	fd, err := r.resolveFile(ref)
+	if err != nil {
+		return "", err
+	}
+	goPackage := fileOptions(fd).GetGoPackage()
+	if idx := strings.LastIndexByte(goPackage, ';'); idx >= 0 {
+		goPackage = goPackage[:idx]
+	}
+	if goPackage == "" {
+		return "", fmt.Errorf("file '%s' has no go_package option", fd.Path())
+	}
+	return goPackage, nil
+}
+
+// goPackageName returns the Go package name of the file containing the
+// specified descriptor, as determined by the go_package option.
+func (r *registry) goPackageName(ref interface{}) (string, error) {
+	fd, err := r.resolveFile(ref)
+	if err != nil {
+		return "", err
+	}
+	goPackage := fileOptions(fd).GetGoPackage()
+	if idx := strings.LastIndexByte(goPackage, ';'); idx >= 0 {
+		return goPackage[idx+1:], nil
+	}
+	importPath, err := r.goImportPath(fd)
+	if err != nil {
+		return "", err
+	}
+	return goSanitized(path.Base(importPath)), nil
+}
+
+// goMemberNames returns the Go names of the fields and oneofs of the
+// specified message. Like protoc-gen-go, it resolves conflicts with generated
+// methods and getters by appending underscores.
+func goMemberNames(
+	md protoreflect.MessageDescriptor,
+) (fields map[protoreflect.Name]string, oneofs map[protoreflect.Name]string) {
+	usedNames := map[string]bool{
+		"Reset":               true,
+		"String":              true,
+		"ProtoMessage":        true,
+		"Marshal":             true,
+		"Unmarshal":           true,
+		"ExtensionRangeArray": true,
+		"ExtensionMap":        true,
+		"Descriptor":          true,
+	}
+	makeNameUnique := func(name string, hasGetter bool) string {
+		for usedNames[name] || (hasGetter && usedNames["Get"+name]) {
+			name += "_"
+		}
+		usedNames[name] = true
+		usedNames["Get"+name] = hasGetter
+		return name
+	}
+	fields = make(map[protoreflect.Name]string)
+	oneofs = make(map[protoreflect.Name]string)
+	fds := md.Fields()
+	for i := 0; i != fds.Len(); i++ {
+		fd := fds.Get(i)
+		fields[fd.Name()] = makeNameUnique(upperCamel(string(fd.Name())), true)
+		if od := fd.ContainingOneof(); od != nil && od.Fields().Get(0) == fd {
+			// For historical reasons, protoc-gen-go assumes no getter for oneofs.
+			oneofs[od.Name()] = makeNameUnique(upperCamel(string(od.Name())), false)
+		}
+	}
+	return fields, oneofs
+}
+
+// goName returns the Go identifier protoc-gen-go generates for the specified
+// descriptor. For fields and oneofs, this is the name of the struct field.
+func (r *registry) goName(ref interface{}) (string, error) {
+	desc, err := r.resolveDescriptor(ref)
+	if err != nil {
+		return "", err
+	}
+	switch x := desc.(type) {
+	case protoreflect.FileDescriptor:
+		return r.goPackageName(x)
+	case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor,
+		protoreflect.ServiceDescriptor:
+		return upperCamel(localName(x)), nil
+	case protoreflect.EnumValueDescriptor:
+		// For historical reasons, enum value names are not camel-cased.
+		parent := x.Parent()
+		if md, ok := parent.Parent().(protoreflect.MessageDescriptor); ok {
+			parent = md
+		}
+		return upperCamel(localName(parent)) + "_" + string(x.Name()), nil
+	case protoreflect.FieldDescriptor:
+		if x.IsExtension() {
+			var parentPrefix string
+			if md, ok := x.Parent().(protoreflect.MessageDescriptor); ok {
+				parentPrefix = upperCamel(localName(md)) + "_"
+			}
+			return "E_" + parentPrefix + upperCamel(string(x.Name())), nil
+		}
+		fields, _ := goMemberNames(x.ContainingMessage())
+		return fields[x.Name()], nil
+	case protoreflect.OneofDescriptor:
+		_, oneofs := goMemberNames(x.Parent().(protoreflect.MessageDescriptor))
+		return oneofs[x.Name()], nil
+	case protoreflect.MethodDescriptor:
+		return upperCamel(string(x.Name())), nil
+	default:
+		return "", fmt.Errorf("unsupported descriptor '%s'", desc.FullName())
+	}
+}
+
+// protocCamelCase converts the specified name to camel case like the protoc
+// Java and C# generators do. If capNext is true, the first letter is
+// capitalised. If preservePeriod is true, periods are kept.
+func protocCamelCase(input string, capNext, preservePeriod bool) string {
+	var b []byte
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case isASCIILower(c):
+			if capNext {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			capNext = false
+		case 'A' <= c && c <= 'Z':
+			if i == 0 && !capNext {
+				// Force first letter to lower case unless explicitly told to
+				// capitalise it.
+				c += 'a' - 'A'
+			}
+			b = append(b, c)
+			capNext = false
+		case isASCIIDigit(c):
+			b = append(b, c)
+			capNext = true
+		default:
+			capNext = true
+			if c == '.' && preservePeriod {
+				b = append(b, c)
+			}
+		}
+	}
+	return string(b)
+}
+
+// javaPackage returns the Java package of the file containing the specified
+// descriptor.
+func (r *registry) javaPackage(ref interface{}) (string, error) {
+	fd, err := r.resolveFile(ref)
+	if err != nil {
+		return "", err
+	}
+	if opts := fileOptions(fd); opts.JavaPackage != nil {
+		return opts.GetJavaPackage(), nil
+	}
+	return string(fd.Package()), nil
+}
+
+// javaOuterClassname returns the name of the Java outer class of the file
+// containing the specified descriptor.
+func (r *registry) javaOuterClassname(ref interface{}) (string, error) {
+	fd, err := r.resolveFile(ref)
+	if err != nil {
+		return "", err
+	}
+	if opts := fileOptions(fd); opts.JavaOuterClassname != nil {
+		return opts.GetJavaOuterClassname(), nil
+	}
+	base := path.Base(fd.Path())
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".proto"), ".protodevel")
+	name := protocCamelCase(base, true, false)
+	if javaClassConflict(fd, name) {
+		name += "OuterClass"
+	}
+	return name, nil
+}
+
+// javaClassConflict reports whether the specified class name conflicts with
+// a type or service defined in the given file.
+func javaClassConflict(fd protoreflect.FileDescriptor, name string) bool {
+	var conflict func(eds protoreflect.EnumDescriptors,
+		mds protoreflect.MessageDescriptors) bool
+	conflict = func(
+		eds protoreflect.EnumDescriptors, mds protoreflect.MessageDescriptors,
+	) bool {
+		for i := 0; i != eds.Len(); i++ {
+			if string(eds.Get(i).Name()) == name {
+				return true
+			}
+		}
+		for i := 0; i != mds.Len(); i++ {
+			md := mds.Get(i)
+			if string(md.Name()) == name || conflict(md.Enums(), md.Messages()) {
+				return true
+			}
+		}
+		return false
+	}
+	sds := fd.Services()
+	for i := 0; i != sds.Len(); i++ {
+		if string(sds.Get(i).Name()) == name {
+			return true
+		}
+	}
+	return conflict(fd.Enums(), fd.Messages())
+}
+
+// javaName returns the Java identifier protoc generates for the specified
+// descriptor. For fields and oneofs, this is the camel case base name of the
+// accessors.
+func (r *registry) javaName(ref interface{}) (string, error) {
+	desc, err := r.resolveDescriptor(ref)
+	if err != nil {
+		return "", err
+	}
+	switch x := desc.(type) {
+	case protoreflect.FileDescriptor:
+		return r.javaOuterClassname(x)
+	case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor,
+		protoreflect.ServiceDescriptor, protoreflect.EnumValueDescriptor:
+		return string(x.Name()), nil
+	case protoreflect.FieldDescriptor, protoreflect.OneofDescriptor,
+		protoreflect.MethodDescriptor:
+		return protocCamelCase(string(x.Name()), false, false), nil
+	default:
+		return "", fmt.Errorf("unsupported descriptor '%s'", desc.FullName())
+	}
+}
+
+// javaFullName returns the fully qualified Java name of the specified
+// descriptor, taking java_package, java_outer_classname and
+// java_multiple_files into account.
+func (r *registry) javaFullName(ref interface{}) (string, error) {
+	desc, err := r.resolveDescriptor(ref)
+	if err != nil {
+		return "", err
+	}
+	var prefix string
+	switch parent := desc.Parent().(type) {
+	case protoreflect.FileDescriptor:
+		if prefix, err = r.javaPackage(parent); err != nil {
+			return "", err
+		}
+		// With java_multiple_files, top-level types have files of their own.
+		standalone := false
+		switch desc.(type) {
+		case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor,
+			protoreflect.ServiceDescriptor:
+			standalone = fileOptions(parent).GetJavaMultipleFiles()
+		}
+		if !standalone {
+			outer, err := r.javaOuterClassname(parent)
+			if err != nil {
+				return "", err
+			}
+			prefix = joinNonEmpty(".", prefix, outer)
+		}
+	case nil: // file
+		if prefix, err = r.javaPackage(desc); err != nil {
+			return "", err
+		}
+	default:
+		if prefix, err = r.javaFullName(parent); err != nil {
+			return "", err
+		}
+	}
+	name, err := r.javaName(desc)
+	if err != nil {
+		return "", err
+	}
+	return joinNonEmpty(".", prefix, name), nil
+}
+
+// csharpNamespace returns the C# namespace of the file containing the
+// specified descriptor.
+func (r *registry) csharpNamespace(ref interface{}) (string, error) {
+	fd, err := r.resolveFile(ref)
+	if err != nil {
+		return "", err
+	}
+	if opts := fileOptions(fd); opts.CsharpNamespace != nil {
+		return opts.GetCsharpNamespace(), nil
+	}
+	return protocCamelCase(string(fd.Package()), true, true), nil
+}
+
+// csharpEnumValueName returns the C# name of the specified enum value of the
+// named enum: the enum name prefix is removed, and the remainder is
+// converted to Pascal case.
+func csharpEnumValueName(enumName, valueName string) string {
+	// Remove the enum name prefix, ignoring case and underscores.
+	var prefix []byte
+	for i := 0; i < len(enumName); i++ {
+		if enumName[i] != '_' {
+			prefix = append(prefix, byte(unicode.ToLower(rune(enumName[i]))))
+		}
+	}
+	stripped := valueName
+	pi, vi := 0, 0
+	for ; pi < len(prefix) && vi < len(valueName); vi++ {
+		if valueName[vi] == '_' {
+			continue
+		}
+		if byte(unicode.ToLower(rune(valueName[vi]))) != prefix[pi] {
+			break
+		}
+		pi++
+	}
+	if pi == len(prefix) {
+		for vi < len(valueName) && valueName[vi] == '_' {
+			vi++
+		}
+		if vi < len(valueName) {
+			stripped = valueName[vi:]
+		}
+	}
+	// Convert SHOUTY_CASE to PascalCase.
+	var b []byte
+	previous := byte('_')
+	for i := 0; i < len(stripped); i++ {
+		c := stripped[i]
+		isAlnum := func(c byte) bool {
+			return isASCIILower(c) || isASCIIDigit(c) || 'A' <= c && c <= 'Z'
+		}
+		switch {
+		case !isAlnum(c):
+		case !isAlnum(previous), isASCIIDigit(previous):
+			b = append(b, byte(unicode.ToUpper(rune(c))))
+		case isASCIILower(previous):
+			b = append(b, c)
+		default:
+			b = append(b, byte(unicode.ToLower(rune(c))))
+		}
+		previous = c
+	}
+	if len(b) > 0 && isASCIIDigit(b[0]) {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// csharpReservedMembers contains the names of the members protoc declares or
+// overrides in generated C# message classes. Field properties with these
+// names get a trailing underscore.
+var csharpReservedMembers = map[string]bool{
+	"Types": true, "Descriptor": true, "Equals": true, "ToString": true,
+	"GetHashCode": true, "WriteTo": true, "Clone": true, "CalculateSize": true,
+	"MergeFrom": true, "OnConstruction": true, "Parser": true,
+}
+
+// csharpName returns the C# identifier protoc generates for the specified
+// descriptor. For fields and oneofs, this is the property name.
+func (r *registry) csharpName(ref interface{}) (string, error) {
+	desc, err := r.resolveDescriptor(ref)
+	if err != nil {
+		return "", err
+	}
+	switch x := desc.(type) {
+	case protoreflect.FileDescriptor:
+		return r.csharpNamespace(x)
+	case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor,
+		protoreflect.ServiceDescriptor, protoreflect.MethodDescriptor:
+		return string(x.Name()), nil
+	case protoreflect.EnumValueDescriptor:
+		return csharpEnumValueName(string(x.Parent().Name()), string(x.Name())), nil
+	case protoreflect.FieldDescriptor, protoreflect.OneofDescriptor:
+		name := protocCamelCase(string(x.Name()), true, false)
+		if len(name) > 0 && isASCIIDigit(name[0]) && x.Name()[0] == '_' {
+			name = "_" + name
+		}
+		if parent := x.Parent(); parent != nil && name == string(parent.Name()) {
+			name += "_"
+		} else if _, ok := x.(protoreflect.FieldDescriptor); ok &&
+			csharpReservedMembers[name] {
+			name += "_"
+		}
+		return name, nil
+	default:
+		return "", fmt.Errorf("unsupported descriptor '%s'", desc.FullName())
+	}
+}
+
+// csharpFullName returns the fully qualified C# name of the specified
+// descriptor. Nested types are contained in the Types class of their parent.
+func (r *registry) csharpFullName(ref interface{}) (string, error) {
+	desc, err := r.resolveDescriptor(ref)
+	if err != nil {
+		return "", err
+	}
+	var prefix string
+	switch parent := desc.Parent().(type) {
+	case nil:
+		return r.csharpNamespace(desc)
+	case protoreflect.FileDescriptor:
+		if prefix, err = r.csharpNamespace(parent); err != nil {
+			return "", err
+		}
+	default:
+		if prefix, err = r.csharpFullName(parent); err != nil {
+			return "", err
+		}
+		switch desc.(type) {
+		case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor:
+			prefix += ".Types"
+		}
+	}
+	name, err := r.csharpName(desc)
+	if err != nil {
+		return "", err
+	}
+	return joinNonEmpty(".", prefix, name), nil
+}
+
+// tsName returns the TypeScript identifier generated for the specified
+// descriptor by ts-proto: nested type names are joined with underscores,
+// fields and oneofs use lower camel case, and enum values keep their names.
+func (r *registry) tsName(ref interface{}) (string, error) {
+	desc, err := r.resolveDescriptor(ref)
+	if err != nil {
+		return "", err
+	}
+	switch x := desc.(type) {
+	case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor:
+		return strings.ReplaceAll(localName(x), ".", "_"), nil
+	case protoreflect.FieldDescriptor, protoreflect.OneofDescriptor:
+		return lowerCamel(string(x.Name())), nil
+	case protoreflect.EnumValueDescriptor, protoreflect.ServiceDescriptor,
+		protoreflect.MethodDescriptor:
+		return string(x.Name()), nil
+	default:
+		return "", fmt.Errorf("unsupported descriptor '%s'", desc.FullName())
+	}
+}
+
+// joinNonEmpty joins the non-empty specified elements with sep.
+func joinNonEmpty(sep string, elems ...string) string {
+	result := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		if elem != "" {
+			result = append(result, elem)
+		}
+	}
+	return strings.Join(result, sep)
+}
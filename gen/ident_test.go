@@ -0,0 +1,107 @@
+package gen
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// identTestRegistry returns a registry with a file acme/shop.proto in package
+// acme.shop, declaring a message Foo with the specified fields, a nested
+// message Bar, and an enum Color.
+func identTestRegistry(t *testing.T, fields ...string) *registry {
+	t.Helper()
+	md := &descriptorpb.DescriptorProto{
+		Name:       proto.String("Foo"),
+		NestedType: []*descriptorpb.DescriptorProto{{Name: proto.String("Bar")}},
+	}
+	for i, field := range fields {
+		md.Field = append(md.Field, &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(field),
+			Number: proto.Int32(int32(i + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		})
+	}
+	r, err := newRegistry([]*descriptorpb.FileDescriptorProto{{
+		Name:        proto.String("acme/shop.proto"),
+		Package:     proto.String("acme.shop"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{md},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Color"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("COLOR_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("COLOR_DARK_RED"), Number: proto.Int32(1)},
+			},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("newRegistry: %v", err)
+	}
+	return r
+}
+
+// TestCsharpName checks csharpName against the names protoc generates, see
+// GetPropertyName and GetEnumValueName in csharp_helpers.cc.
+func TestCsharpName(t *testing.T) {
+	r := identTestRegistry(t,
+		"foo_bar", "field_2", "foo", "types", "descriptor", "equals",
+		"to_string", "get_hash_code", "write_to", "clone", "calculate_size",
+		"merge_from", "on_construction", "parser", "parser_name",
+	)
+	tests := []struct {
+		ref, want string
+	}{
+		{"acme.shop.Foo", "Foo"},
+		{"acme.shop.Foo.foo_bar", "FooBar"},
+		{"acme.shop.Foo.field_2", "Field2"},
+		{"acme.shop.Foo.foo", "Foo_"},
+		{"acme.shop.Foo.types", "Types_"},
+		{"acme.shop.Foo.descriptor", "Descriptor_"},
+		{"acme.shop.Foo.equals", "Equals_"},
+		{"acme.shop.Foo.to_string", "ToString_"},
+		{"acme.shop.Foo.get_hash_code", "GetHashCode_"},
+		{"acme.shop.Foo.write_to", "WriteTo_"},
+		{"acme.shop.Foo.clone", "Clone_"},
+		{"acme.shop.Foo.calculate_size", "CalculateSize_"},
+		{"acme.shop.Foo.merge_from", "MergeFrom_"},
+		{"acme.shop.Foo.on_construction", "OnConstruction_"},
+		{"acme.shop.Foo.parser", "Parser_"},
+		{"acme.shop.Foo.parser_name", "ParserName"},
+		{"acme.shop.COLOR_DARK_RED", "DarkRed"},
+		{"acme/shop.proto", "Acme.Shop"},
+	}
+	for _, test := range tests {
+		got, err := r.csharpName(test.ref)
+		if err != nil {
+			t.Errorf("csharpName(%q): %v", test.ref, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("csharpName(%q) = %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
+
+func TestCsharpFullName(t *testing.T) {
+	r := identTestRegistry(t, "parser")
+	tests := []struct {
+		ref, want string
+	}{
+		{"acme.shop.Foo", "Acme.Shop.Foo"},
+		{"acme.shop.Foo.Bar", "Acme.Shop.Foo.Types.Bar"},
+		{"acme.shop.Foo.parser", "Acme.Shop.Foo.Parser_"},
+	}
+	for _, test := range tests {
+		got, err := r.csharpFullName(test.ref)
+		if err != nil {
+			t.Errorf("csharpFullName(%q): %v", test.ref, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("csharpFullName(%q) = %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// goImportName returns the default package name for the specified Go import
+// path, i. e., its sanitized last element.
+func goImportName(importPath string) string {
+	return goSanitized(path.Base(importPath))
+}
+
+// goImport registers the Go package with the specified import path for the
+// goImports block and returns the package name to qualify identifiers with.
+// The package name defaults to the last element of the import path, but can
+// be specified explicitly. If the name is already in use by another package,
+// a number is appended. The generated package itself is not imported, and its
+// name is the empty string.
+func (s *execState) goImport(importPath string, name ...string) (string, error) {
+	if importPath == "" {
+		return "", errors.New("empty import path")
+	}
+	if importPath == s.goImportPath {
+		return "", nil
+	}
+	if prev, ok := s.goImports[importPath]; ok {
+		return prev, nil
+	}
+	var baseName string
+	switch len(name) {
+	case 0:
+		baseName = goImportName(importPath)
+	case 1:
+		baseName = goSanitized(name[0])
+	default:
+		return "", fmt.Errorf("expected at most one package name, got %d",
+			len(name))
+	}
+	used := make(map[string]bool, len(s.goImports))
+	for _, usedName := range s.goImports {
+		used[usedName] = true
+	}
+	result := baseName
+	for i := 1; used[result]; i++ {
+		result = baseName + strconv.Itoa(i)
+	}
+	if s.goImports == nil {
+		s.goImports = make(map[string]string)
+	}
+	s.goImports[importPath] = result
+	return result, nil
+}
+
+// qualifiedGoIdent returns the Go identifier of the specified descriptor (see
+// goName), qualified with the package name of its file if the file does not
+// belong to the generated package, and registers the package for the
+// goImports block. Alternatively, an arbitrary Go identifier and its import
+// path can be specified, e. g., qualifiedGoIdent "Context" "context".
+func (s *execState) qualifiedGoIdent(
+	ref interface{}, importPath ...string,
+) (string, error) {
+	var name, pkgName string
+	switch len(importPath) {
+	case 0:
+		desc, err := s.registry.resolveDescriptor(ref)
+		if err != nil {
+			return "", err
+		}
+		switch x := desc.(type) {
+		case protoreflect.FileDescriptor, protoreflect.OneofDescriptor:
+			return "", fmt.Errorf("'%s' is not a package level Go identifier",
+				desc.FullName())
+		case protoreflect.FieldDescriptor:
+			if !x.IsExtension() {
+				return "", fmt.Errorf("'%s' is not a package level Go identifier",
+					desc.FullName())
+			}
+		}
+		if name, err = s.registry.goName(desc); err != nil {
+			return "", err
+		}
+		goPath, err := s.registry.goImportPath(desc)
+		if err != nil {
+			return "", err
+		}
+		hint, err := s.registry.goPackageName(desc)
+		if err != nil {
+			return "", err
+		}
+		if pkgName, err = s.goImport(goPath, hint); err != nil {
+			return "", err
+		}
+	case 1:
+		name = stringOf(ref)
+		var err error
+		if pkgName, err = s.goImport(importPath[0]); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("expected at most one import path, got %d",
+			len(importPath))
+	}
+	if pkgName == "" {
+		return name, nil
+	}
+	return pkgName + "." + name, nil
+}
+
+// isGoStdlib reports whether the specified import path belongs to the Go
+// standard library, i. e., its first element contains no dot.
+func isGoStdlib(importPath string) bool {
+	first := importPath
+	if idx := strings.IndexByte(importPath, '/'); idx >= 0 {
+		first = importPath[:idx]
+	}
+	return !strings.Contains(first, ".")
+}
+
+// goImportsBlock renders the Go packages registered so far as an import
+// declaration, with standard library packages grouped first. Packages are
+// imported with their name unless it is the unsanitized last element of the
+// import path. If no packages have been registered, the empty string is
+// returned.
+func (s *execState) goImportsBlock() string {
+	if len(s.goImports) == 0 {
+		return ""
+	}
+	var std, other []string
+	for importPath, name := range s.goImports {
+		spec := strconv.Quote(importPath)
+		if name != path.Base(importPath) {
+			spec = name + " " + spec
+		}
+		if isGoStdlib(importPath) {
+			std = append(std, spec)
+		} else {
+			other = append(other, spec)
+		}
+	}
+	byPath := func(specs []string) func(i, j int) bool {
+		return func(i, j int) bool {
+			return specs[i][strings.IndexByte(specs[i], '"'):] <
+				specs[j][strings.IndexByte(specs[j], '"'):]
+		}
+	}
+	sort.Slice(std, byPath(std))
+	sort.Slice(other, byPath(other))
+	var sb strings.Builder
+	sb.WriteString("import (\n")
+	for _, spec := range std {
+		sb.WriteString("\t" + spec + "\n")
+	}
+	if len(std) > 0 && len(other) > 0 {
+		sb.WriteByte('\n')
+	}
+	for _, spec := range other {
+		sb.WriteString("\t" + spec + "\n")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// require records that the generated code requires the specified value of
+// the given kind, e. g., require "include" "stdint.h", so that a header
+// template can list all requirements with the required function.
+// It always returns the empty string.
+func (s *execState) require(kind string, value interface{}) string {
+	if s.requirements == nil {
+		s.requirements = make(map[string]map[string]bool)
+	}
+	if s.requirements[kind] == nil {
+		s.requirements[kind] = make(map[string]bool)
+	}
+	s.requirements[kind][stringOf(value)] = true
+	return ""
+}
+
+// required returns the sorted values of the specified kind recorded so far
+// with the require function.
+func (s *execState) required(kind string) []string {
+	result := make([]string, 0, len(s.requirements[kind]))
+	for value := range s.requirements[kind] {
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,26 @@
+package gen
+
+import "testing"
+
+func TestGoImportsBlock(t *testing.T) {
+	s := &execState{goImportPath: "example.com/self"}
+	for _, importPath := range []string{
+		"fmt", "gopkg.in/yaml.v3", "example.com/foo-bar", "example.com/baz",
+		"example.com/other/baz", "example.com/self",
+	} {
+		if _, err := s.goImport(importPath); err != nil {
+			t.Fatalf("goImport(%q): %v", importPath, err)
+		}
+	}
+	want := `import (
+	"fmt"
+
+	"example.com/baz"
+	foo_bar "example.com/foo-bar"
+	baz1 "example.com/other/baz"
+	yaml_v3 "gopkg.in/yaml.v3"
+)`
+	if got := s.goImportsBlock(); got != want {
+		t.Errorf("goImportsBlock() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxIncludeDepth is the maximum nesting depth of include and tpl calls.
+const maxIncludeDepth = 100
+
+// tplName is the template name used for templates rendered with tpl.
+const tplName = "<tpl>"
+
+// depthError is returned when the maximum include depth is exceeded.
+type depthError struct {
+	// what describes the call exceeding the maximum depth.
+	what string
+}
+
+// Error implements error.Error.
+func (de *depthError) Error() string {
+	return fmt.Sprintf("%s: maximum nesting depth %d exceeded",
+		de.what, maxIncludeDepth)
+}
+
+// passDepthError returns the depthError within err, if any, so that it is not
+// wrapped once for each nesting level. Otherwise, err is returned unchanged.
+func passDepthError(err error) error {
+	var de *depthError
+	if errors.As(err, &de) {
+		return de
+	}
+	return err
+}
+
+// enter increments the include depth of this execution state. The returned
+// function decrements it again.
+func (s *execState) enter(what string) (func(), error) {
+	if s.depth >= maxIncludeDepth {
+		return nil, &depthError{what}
+	}
+	s.depth++
+	return func() { s.depth-- }, nil
+}
+
+// include executes the named template with the specified data and returns
+// the result as a string, so that it can be piped.
+func (s *execState) include(name string, data interface{}) (string, error) {
+	leave, err := s.enter(fmt.Sprintf("include '%s'", name))
+	if err != nil {
+		return "", err
+	}
+	defer leave()
+	var sb strings.Builder
+	if err = s.tpl.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", passDepthError(err)
+	}
+	return sb.String(), nil
+}
+
+// renderTpl parses the specified text as a template and executes it with the
+// given data. The text may refer to all named templates. Templates defined
+// by the text are local to this call.
+func (s *execState) renderTpl(text string, data interface{}) (string, error) {
+	leave, err := s.enter("tpl")
+	if err != nil {
+		return "", err
+	}
+	defer leave()
+	t, err := s.tpl.Clone()
+	if err != nil {
+		return "", fmt.Errorf("clone template: %w", err)
+	}
+	if t, err = t.New(tplName).Parse(text); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err = t.Execute(&sb, data); err != nil {
+		return "", passDepthError(err)
+	}
+	return sb.String(), nil
+}
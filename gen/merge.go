@@ -5,21 +5,19 @@ import (
 	"fmt"
 	"sort"
 
-	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
-	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
-// mergeData merges the data from the specified files into target.
-func mergeData(
+// mergeData merges the data from the files of this registry into target.
+func (r *registry) mergeData(
 	target protoreflect.Message,
 	msgxt protoreflect.ExtensionType, msgFields []protoreflect.Name,
 ) error {
 	// Create deterministic file order.
-	fds := make([]protoreflect.FileDescriptor, 0,
-		protoregistry.GlobalFiles.NumFiles())
-	protoregistry.GlobalFiles.RangeFiles(
+	fds := make([]protoreflect.FileDescriptor, 0, r.files.NumFiles())
+	r.files.RangeFiles(
 		func(fd protoreflect.FileDescriptor) bool {
 			fds = append(fds, fd)
 			return true
@@ -30,7 +28,7 @@ func mergeData(
 	})
 	// merge file data
 	for _, fd := range fds {
-		if err := mergeDataFromFile(target, fd, msgxt, msgFields); err != nil {
+		if err := r.mergeDataFromFile(target, fd, msgxt, msgFields); err != nil {
 			return fmt.Errorf("merge from file '%s': %w", fd.Path(), err)
 		}
 	}
@@ -38,14 +36,14 @@ func mergeData(
 }
 
 // mergeDataFromFile merges the data from the specified file into target.
-func mergeDataFromFile(
+func (r *registry) mergeDataFromFile(
 	target protoreflect.Message, fd protoreflect.FileDescriptor,
 	msgxt protoreflect.ExtensionType, msgFields []protoreflect.Name,
 ) error {
 	mds := fd.Messages()
 	for i := 0; i != mds.Len(); i++ {
 		md := mds.Get(i)
-		if err := mergeDataFromMsg(target, md, msgxt, msgFields); err != nil {
+		if err := r.mergeDataFromMsg(target, md, msgxt, msgFields); err != nil {
 			return fmt.Errorf("merge from message '%s': %w", md.FullName(), err)
 		}
 	}
@@ -53,40 +51,54 @@ func mergeDataFromFile(
 }
 
 // mergeDataFromMsg merges the data from the specified message into target.
-func mergeDataFromMsg(
+func (r *registry) mergeDataFromMsg(
 	target protoreflect.Message, md protoreflect.MessageDescriptor,
 	msgxt protoreflect.ExtensionType, msgFields []protoreflect.Name,
-) (err error) {
+) error {
 	// process nested messages first
 	mds := md.Messages()
 	for i := 0; i != mds.Len(); i++ {
 		submd := mds.Get(i)
-		if err := mergeDataFromMsg(target, submd, msgxt, msgFields); err != nil {
+		if err := r.mergeDataFromMsg(target, submd, msgxt, msgFields); err != nil {
 			return fmt.Errorf("merge from nested message '%s': %w",
 				submd.FullName(), err)
 		}
 	}
 	// now process options
-	msgOpt := md.Options()
-	var xtMsg protoreflect.Message
-	if proto.HasExtension(msgOpt, msgxt) {
-		xtMsg = proto.GetExtension(msgOpt, msgxt).(protoreflect.Message)
-	} else {
-		// Extension might hide in unknown fields
-		if xtMsg, err = extractUnknown(
-			msgOpt.ProtoReflect().GetUnknown(), msgxt,
-		); err != nil {
-			return fmt.Errorf("extract option from unknown fields: %w", err)
-		}
-		if xtMsg == nil {
-			return nil
-		}
+	opt, ok, err := r.getOption(md.Options(), msgxt)
+	if err != nil {
+		return fmt.Errorf("get option '%s': %w", msgxt.TypeDescriptor().FullName(),
+			err)
 	}
-	return mergeDataFromOpt(target, xtMsg, msgFields)
+	if !ok {
+		return nil
+	}
+	return mergeDataFromOpt(target, msgxt.TypeDescriptor(), opt, msgFields)
 }
 
-// mergeDataFromOpt merges the data from the specified option field into target.
+// mergeDataFromOpt merges the data from the specified value of the given
+// option field into target. The elements of repeated fields are merged in
+// order.
 func mergeDataFromOpt(
+	target protoreflect.Message, fd protoreflect.FieldDescriptor,
+	v protoreflect.Value, msgFields []protoreflect.Name,
+) error {
+	if !fd.IsList() {
+		return mergeDataFromOptMsg(target, v.Message(), msgFields)
+	}
+	list := v.List()
+	for i := 0; i != list.Len(); i++ {
+		err := mergeDataFromOptMsg(target, list.Get(i).Message(), msgFields)
+		if err != nil {
+			return fmt.Errorf("merge element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// mergeDataFromOptMsg merges the data from the specified option message into
+// target.
+func mergeDataFromOptMsg(
 	target, opt protoreflect.Message, msgFields []protoreflect.Name,
 ) error {
 	if len(msgFields) == 0 {
@@ -96,8 +108,7 @@ func mergeDataFromOpt(
 	if !opt.Has(field) {
 		return nil
 	}
-	subopt := opt.Get(field).Interface().(protoreflect.Message)
-	return mergeDataFromOpt(target, subopt, msgFields[1:])
+	return mergeDataFromOpt(target, field, opt.Get(field), msgFields[1:])
 }
 
 // mergeMsg merges the given source message into the target message.
@@ -135,15 +146,26 @@ func mergeMsg(target, src protoreflect.Message) error {
 			return fmt.Errorf("merge field '%s': %w", fd.FullName(), err)
 		}
 	}
+	// Keep unknown fields, so that they can be reported.
+	if raw := src.GetUnknown(); len(raw) > 0 {
+		target.SetUnknown(append(append(protoreflect.RawFields{},
+			target.GetUnknown()...), raw...))
+	}
 	return nil
 }
 
 // mergeField merges the given value into target at the specified field
-// descriptor.
+// descriptor. Extension fields are merged like other fields, but must extend
+// the target message.
 func mergeField(
 	target protoreflect.Message, fd protoreflect.FieldDescriptor,
 	v protoreflect.Value,
 ) error {
+	if fd.IsExtension() &&
+		fd.ContainingMessage().FullName() != target.Descriptor().FullName() {
+		return fmt.Errorf("extension does not extend '%s'",
+			target.Descriptor().FullName())
+	}
 	switch {
 	case fd.IsList():
 		return mergeList(target.Mutable(fd).List(), v.List())
@@ -190,31 +212,36 @@ func mergeMap(target, src protoreflect.Map) (err error) {
 	return
 }
 
-// extractUnknown attempts to extract a message of the specified extension
-// type from the given concatenation of raw fields. If the field could not
-// be found, extractUnknown returns (nil, nil).
-func extractUnknown(
-	rawFields []byte, xt protoreflect.ExtensionType,
-) (protoreflect.Message, error) {
-	xtidx := xt.TypeDescriptor().Number()
-	for len(rawFields) > 0 {
-		idx, typ, n := protowire.ConsumeField(rawFields)
-		if n < 0 {
-			return nil, fmt.Errorf("parsing raw field: %w", protowire.ParseError(n))
-		}
-		if idx != xtidx {
-			rawFields = rawFields[n:]
-			continue
-		}
-		if typ != protowire.BytesType {
-			return nil, fmt.Errorf("bad type for extension message: %d", typ)
-		}
-		rawMsg, _ := protowire.ConsumeBytes(rawFields[protowire.SizeTag(idx):])
-		msg := xt.New().Message().Interface()
-		if err := proto.Unmarshal(rawMsg, msg); err != nil {
-			return nil, fmt.Errorf("unmarshal extension message: %w", err)
-		}
-		return msg.ProtoReflect(), nil
+// getOption returns the value of the specified extension field in the given
+// options message. If the extension field is not set, ok is false. Template
+// data is only read from google.protobuf.MessageOptions, but getOption does
+// not depend on the kind of options.
+//
+// Options are parsed with the descriptor.proto compiled into the plugin,
+// which knows neither the extension nor fields added in newer versions, so
+// they keep such fields in their unknown fields, possibly split across
+// several occurrences. The options are therefore reparsed with the schema of
+// the extended message from the code generator request. This merges all
+// occurrences as per protobuf semantics and decodes groups and packed or
+// unpacked repeated scalars.
+func (r *registry) getOption(
+	opts protoreflect.ProtoMessage, xt protoreflect.ExtensionType,
+) (value protoreflect.Value, ok bool, err error) {
+	xtd := xt.TypeDescriptor()
+	raw, err := proto.Marshal(opts.ProtoReflect().Interface())
+	if err != nil {
+		return protoreflect.Value{}, false,
+			fmt.Errorf("marshal options: %w", err)
+	}
+	m := dynamicpb.NewMessage(xtd.ContainingMessage())
+	if err = (proto.UnmarshalOptions{
+		Resolver: r.types,
+	}).Unmarshal(raw, m); err != nil {
+		return protoreflect.Value{}, false,
+			fmt.Errorf("unmarshal options: %w", err)
+	}
+	if !m.Has(xtd) {
+		return protoreflect.Value{}, false, nil
 	}
-	return nil, nil
+	return m.Get(xtd), true, nil
 }
@@ -0,0 +1,241 @@
+package gen
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// mergeTestSchema returns acme/data.proto, which declares the message option
+// acme.data of type acme.Data and the repeated message option acme.datas of
+// the same type, and the group option acme.settings of type acme.Settings.
+// Data is extended by the repeated extension
+// acme.tags and by the message extension acme.info of type acme.Info.
+func mergeTestSchema() []*descriptorpb.FileDescriptorProto {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	return []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(
+			descriptorpb.File_google_protobuf_descriptor_proto),
+		{
+			Name:       proto.String("acme/data.proto"),
+			Package:    proto.String("acme"),
+			Dependency: []string{"google/protobuf/descriptor.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Data"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name: proto.String("name"), Number: proto.Int32(1),
+					Label: optional, Type: str,
+				}},
+				ExtensionRange: []*descriptorpb.DescriptorProto_ExtensionRange{{
+					Start: proto.Int32(100), End: proto.Int32(200),
+				}},
+			}, {
+				Name: proto.String("Settings"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name: proto.String("name"), Number: proto.Int32(1),
+					Label: optional, Type: str,
+				}, {
+					Name: proto.String("tags"), Number: proto.Int32(2),
+					Label: repeated, Type: str,
+				}},
+			}, {
+				Name: proto.String("Info"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name: proto.String("a"), Number: proto.Int32(1),
+					Label: optional, Type: str,
+				}, {
+					Name: proto.String("b"), Number: proto.Int32(2),
+					Label: optional, Type: str,
+				}},
+			}},
+			Extension: []*descriptorpb.FieldDescriptorProto{{
+				Name: proto.String("data"), Number: proto.Int32(50000),
+				Label: optional, Type: msg, TypeName: proto.String(".acme.Data"),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			}, {
+				Name: proto.String("datas"), Number: proto.Int32(50001),
+				Label: repeated, Type: msg, TypeName: proto.String(".acme.Data"),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			}, {
+				Name: proto.String("settings"), Number: proto.Int32(50002),
+				Label:    optional,
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum(),
+				TypeName: proto.String(".acme.Settings"),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			}, {
+				Name: proto.String("tags"), Number: proto.Int32(100),
+				Label: repeated, Type: str, Extendee: proto.String(".acme.Data"),
+			}, {
+				Name: proto.String("info"), Number: proto.Int32(101),
+				Label: optional, Type: msg, TypeName: proto.String(".acme.Info"),
+				Extendee: proto.String(".acme.Data"),
+			}},
+		},
+	}
+}
+
+// mergeTestFile returns a file with the specified path and a message whose
+// specified option is set to the given text format data, once for each
+// element. The option is encoded in the unknown fields of the options.
+func mergeTestFile(
+	t *testing.T, r *registry, path string, fd protoreflect.FieldDescriptor,
+	data ...string,
+) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+	dataType, err := r.types.FindMessageByName(fd.Message().FullName())
+	if err != nil {
+		t.Fatalf("find %s: %v", fd.Message().FullName(), err)
+	}
+	var unknown []byte
+	for _, d := range data {
+		msg := dataType.New().Interface()
+		if err = (prototext.UnmarshalOptions{
+			Resolver: r.types,
+		}).Unmarshal([]byte(d), msg); err != nil {
+			t.Fatalf("unmarshal %q: %v", d, err)
+		}
+		raw, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatalf("marshal %q: %v", d, err)
+		}
+		if fd.Kind() == protoreflect.GroupKind {
+			unknown = protowire.AppendTag(unknown, fd.Number(),
+				protowire.StartGroupType)
+			unknown = append(unknown, raw...)
+			unknown = protowire.AppendTag(unknown, fd.Number(),
+				protowire.EndGroupType)
+			continue
+		}
+		unknown = protowire.AppendBytes(
+			protowire.AppendTag(unknown, fd.Number(), protowire.BytesType), raw)
+	}
+	opts := &descriptorpb.MessageOptions{}
+	opts.ProtoReflect().SetUnknown(unknown)
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(path),
+		Package:    proto.String("acme"),
+		Dependency: []string{"acme/data.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:    proto.String(upperCamel(path[:len(path)-len(".proto")])),
+			Options: opts,
+		}},
+	}
+}
+
+// mergeTestData merges the acme.data options of files with the specified text
+// format data and returns the raw result.
+func mergeTestData(t *testing.T, data ...string) (message, error) {
+	t.Helper()
+	return mergeTestOption(t, "acme.data", data...)
+}
+
+// mergeTestOption merges the specified options of files, one for each
+// element of data, and returns the raw result. Repeated options are set
+// to two elements of the same data.
+func mergeTestOption(
+	t *testing.T, option string, data ...string,
+) (message, error) {
+	t.Helper()
+	schema := mergeTestSchema()
+	r, err := newRegistry(schema)
+	if err != nil {
+		t.Fatalf("newRegistry: %v", err)
+	}
+	xt, err := r.types.FindExtensionByName(protoreflect.FullName(option))
+	if err != nil {
+		t.Fatalf("find extension %s: %v", option, err)
+	}
+	xtd := xt.TypeDescriptor()
+	fdpbs := schema
+	for i, d := range data {
+		elems := []string{d}
+		if xtd.IsList() {
+			elems = append(elems, d)
+		}
+		fdpbs = append(fdpbs, mergeTestFile(t, r,
+			fmt.Sprintf("file%d.proto", i), xtd, elems...))
+	}
+	if r, err = newRegistry(fdpbs); err != nil {
+		t.Fatalf("newRegistry: %v", err)
+	}
+	msgxt, target, err := r.getExtensions(options{Message: &optionPath{
+		OptionFieldName: protoreflect.FullName(option),
+	}})
+	if err != nil {
+		t.Fatalf("getExtensions: %v", err)
+	}
+	if err = r.mergeData(target, msgxt, nil); err != nil {
+		return nil, err
+	}
+	result, err := r.makeRawMessage(target)
+	if err != nil {
+		t.Fatalf("makeRawMessage: %v", err)
+	}
+	return result, nil
+}
+
+func TestMergeExtensions(t *testing.T) {
+	got, err := mergeTestData(t,
+		`name: "n" [acme.tags]: "x" [acme.info] { a: "a" }`,
+		`[acme.tags]: "y" [acme.tags]: "z"`,
+	)
+	if err != nil {
+		t.Fatalf("mergeData: %v", err)
+	}
+	if tags := got["[acme.tags]"]; !reflect.DeepEqual(tags,
+		[]interface{}{"x", "y", "z"}) {
+		t.Errorf("[acme.tags] = %#v, want x, y, z", tags)
+	}
+	info, _ := got["[acme.info]"].(message)
+	if info["a"] != "a" || info["b"] != "" {
+		t.Errorf("[acme.info] = %v, want a: \"a\"", info)
+	}
+	if got["name"] != "n" {
+		t.Errorf("name = %#v, want \"n\"", got["name"])
+	}
+}
+
+func TestMergeExtensionConflict(t *testing.T) {
+	for _, data := range [][]string{
+		{`[acme.info] { a: "a" }`, `[acme.info] { b: "b" }`},
+		{`name: "n"`, `name: "m"`},
+	} {
+		if _, err := mergeTestData(t, data...); err == nil {
+			t.Errorf("merging %q succeeded", data)
+		}
+	}
+}
+
+func TestMergeRepeatedOption(t *testing.T) {
+	got, err := mergeTestOption(t, "acme.datas",
+		`[acme.tags]: "x"`, `[acme.tags]: "y"`)
+	if err != nil {
+		t.Fatalf("mergeData: %v", err)
+	}
+	if tags := got["[acme.tags]"]; !reflect.DeepEqual(tags,
+		[]interface{}{"x", "x", "y", "y"}) {
+		t.Errorf("[acme.tags] = %#v, want x, x, y, y", tags)
+	}
+}
+
+func TestMergeGroupOption(t *testing.T) {
+	got, err := mergeTestOption(t, "acme.settings",
+		`name: "n" tags: "x"`, `tags: "y"`)
+	if err != nil {
+		t.Fatalf("mergeData: %v", err)
+	}
+	if got["name"] != "n" ||
+		!reflect.DeepEqual(got["tags"], []interface{}{"x", "y"}) {
+		t.Errorf("merged settings = %v, want name n and tags x, y", got)
+	}
+}
@@ -6,12 +6,17 @@ import (
 	"sort"
 	"strings"
 
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // origMsg is the message key to the original protobuf message.
 const origMsg = "_protomsg"
 
+// anyTypeKey is the message key to the type URL of an expanded
+// google.protobuf.Any message.
+const anyTypeKey = "@type"
+
 // enumValue describes an enum value as a string.
 type enumValue string
 
@@ -20,6 +25,20 @@ func (ev enumValue) String() string {
 	return string(ev)
 }
 
+// oneofCase describes the active case of a oneof.
+type oneofCase struct {
+	// Case is the name of the active field, or empty if no field is set.
+	Case string
+
+	// Value is the value of the active field, or nil if no field is set.
+	Value interface{}
+}
+
+// String returns the name of the active field.
+func (oc oneofCase) String() string {
+	return oc.Case
+}
+
 // kvpair describes a key-value pair.
 type kvpair struct {
 	key, value interface{}
@@ -147,7 +166,7 @@ func mapString(indent string, value reflect.Value) string {
 	if len(pairs) == 0 {
 		return "{}"
 	}
-	pairStrings := make([]string, len(pairs))
+	pairStrings := make([]string, 0, len(pairs))
 	for _, pair := range pairs {
 		if value.Type().Key().Kind() == reflect.String {
 			pairStrings = append(pairStrings, fmt.Sprintf("%s  %q: %s",
@@ -169,7 +188,7 @@ func mapString(indent string, value reflect.Value) string {
 }
 
 // makeRawMessage converts the specified source message to a raw message.
-func makeRawMessage(src protoreflect.Message) message {
+func (r *registry) makeRawMessage(src protoreflect.Message) (message, error) {
 	result := make(message)
 	result[origMsg] = src.Interface()
 	// Set all fields, including unpopulated ones (unless they're oneofs).
@@ -179,6 +198,9 @@ func makeRawMessage(src protoreflect.Message) message {
 		if !src.Has(fd) {
 			switch {
 			case fd.ContainingOneof() != nil: // omit this field
+			case fd.Kind() == protoreflect.MessageKind && !fd.IsList() &&
+				!fd.IsMap() && getWKTType(fd.Message()) != nil:
+				result[string(fd.Name())] = nil
 			case fd.Kind() == protoreflect.EnumKind:
 				switch {
 				case fd.IsList():
@@ -194,54 +216,166 @@ func makeRawMessage(src protoreflect.Message) message {
 			}
 			continue
 		}
-		v := src.Get(fd)
-		switch {
-		case fd.IsList():
-			result[string(fd.Name())] = makeRawList(v.List())
-		case fd.IsMap():
-			result[string(fd.Name())] = makeRawMap(getFieldType(fd), v.Map())
-		case fd.Kind() == protoreflect.EnumKind:
-			result[string(fd.Name())] =
-				enumValue(fd.Enum().Values().ByNumber(v.Enum()).Name())
-		case fd.Kind() == protoreflect.MessageKind:
-			result[string(fd.Name())] = makeRawMessage(v.Message())
-		default:
-			result[string(fd.Name())] = v.Interface()
+		value, err := r.makeRawField(fd, src.Get(fd))
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %w", fd.Name(), err)
+		}
+		result[string(fd.Name())] = value
+	}
+	// Add populated extension fields under their bracketed full name.
+	var err error
+	src.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !fd.IsExtension() {
+			return true
+		}
+		var value interface{}
+		if value, err = r.makeRawField(fd, v); err != nil {
+			err = fmt.Errorf("extension '%s': %w", fd.FullName(), err)
+			return false
+		}
+		result[extensionKey(fd)] = value
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Expose unknown fields for debugging.
+	if raw := src.GetUnknown(); len(raw) > 0 {
+		if result[unknownKey], err = decodeUnknown(raw); err != nil {
+			return nil, fmt.Errorf("decode unknown fields: %w", err)
+		}
+	}
+	// Expose the active case of each oneof under the oneof name.
+	// Synthetic oneofs of proto3 optional fields are of no interest.
+	oneofs := src.Descriptor().Oneofs()
+	for i := 0; i != oneofs.Len(); i++ {
+		od := oneofs.Get(i)
+		if od.IsSynthetic() {
+			continue
 		}
+		var oc oneofCase
+		if fd := src.WhichOneof(od); fd != nil {
+			oc = oneofCase{string(fd.Name()), result[string(fd.Name())]}
+		}
+		result[string(od.Name())] = oc
+	}
+	return result, nil
+}
+
+// makeRawField converts the specified value of the given populated field to
+// its raw representation.
+func (r *registry) makeRawField(
+	fd protoreflect.FieldDescriptor, v protoreflect.Value,
+) (interface{}, error) {
+	switch {
+	case fd.IsList():
+		return r.makeRawList(v.List())
+	case fd.IsMap():
+		return r.makeRawMap(getFieldType(fd), v.Map())
+	case fd.Kind() == protoreflect.EnumKind:
+		return enumValue(fd.Enum().Values().ByNumber(v.Enum()).Name()), nil
+	case fd.Kind() == protoreflect.MessageKind:
+		return r.makeRawValue(v.Message())
+	default:
+		return v.Interface(), nil
 	}
-	return result
+}
+
+// extensionKey returns the raw message key of the specified extension field,
+// i. e., its full name in brackets, as in the protobuf text format.
+func extensionKey(xd protoreflect.FieldDescriptor) string {
+	return "[" + string(xd.FullName()) + "]"
+}
+
+// makeRawValue converts the specified source message to its native
+// representation if it is a well-known type with one (see makeWKTValue), and
+// to a raw message otherwise. The payload of a google.protobuf.Any is
+// expanded (see makeRawAny).
+func (r *registry) makeRawValue(src protoreflect.Message) (interface{}, error) {
+	if src.Descriptor().FullName() == anyName {
+		return r.makeRawAny(src)
+	}
+	if value, ok := makeWKTValue(src); ok {
+		return value, nil
+	}
+	return r.makeRawMessage(src)
+}
+
+// makeRawAny unmarshals the payload of the specified google.protobuf.Any
+// message and converts it to a raw message with the type URL under the
+// anyTypeKey. If the payload has a native representation (see makeWKTValue),
+// the representation is stored under the "value" key, as in the protobuf
+// JSON mapping.
+func (r *registry) makeRawAny(src protoreflect.Message) (message, error) {
+	typeURL := wktField(src, "type_url").String()
+	mt, err := r.types.FindMessageByURL(typeURL)
+	if err != nil {
+		return nil, fmt.Errorf("resolve Any type URL '%s': %w", typeURL, err)
+	}
+	payload := mt.New()
+	if err = (proto.UnmarshalOptions{
+		Resolver: r.types,
+	}).Unmarshal(wktField(src, "value").Bytes(), payload.Interface()); err != nil {
+		return nil, fmt.Errorf("unmarshal Any payload of type '%s': %w",
+			mt.Descriptor().FullName(), err)
+	}
+	value, err := r.makeRawValue(payload)
+	if err != nil {
+		return nil, fmt.Errorf("convert Any payload of type '%s': %w",
+			mt.Descriptor().FullName(), err)
+	}
+	result, ok := value.(message)
+	if !ok {
+		result = message{"value": value}
+	}
+	result[anyTypeKey] = typeURL
+	return result, nil
 }
 
 // makeRawList converts the specified source list to a raw list.
-func makeRawList(list protoreflect.List) []interface{} {
+func (r *registry) makeRawList(list protoreflect.List) ([]interface{}, error) {
 	result := make([]interface{}, list.Len())
 	for i := range result {
 		elem := list.Get(i)
 		switch x := elem.Interface().(type) {
 		case protoreflect.Message:
-			result[i] = makeRawMessage(x)
+			var err error
+			if result[i], err = r.makeRawValue(x); err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
 		default:
 			result[i] = elem.Interface()
 		}
 	}
-	return result
+	return result, nil
 }
 
 // makeRawMap converts the specified source map to a map of the specified type.
-func makeRawMap(mapType reflect.Type, m protoreflect.Map) interface{} {
+func (r *registry) makeRawMap(
+	mapType reflect.Type, m protoreflect.Map,
+) (interface{}, error) {
 	result := reflect.MakeMapWithSize(mapType, m.Len())
+	var err error
 	m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
-		switch x := v.Interface().(type) {
-		case protoreflect.Message:
-			result.SetMapIndex(reflect.ValueOf(k.Interface()),
-				reflect.ValueOf(makeRawMessage(x)))
-		default:
-			result.SetMapIndex(reflect.ValueOf(k.Interface()),
-				reflect.ValueOf(v.Interface()))
+		elem := v.Interface()
+		if x, ok := elem.(protoreflect.Message); ok {
+			if elem, err = r.makeRawValue(x); err != nil {
+				err = fmt.Errorf("key '%v': %w", k.Interface(), err)
+				return false
+			}
+		}
+		velem := reflect.ValueOf(elem)
+		if elem == nil {
+			// E. g., a null google.protobuf.Value.
+			velem = reflect.Zero(mapType.Elem())
 		}
+		result.SetMapIndex(reflect.ValueOf(k.Interface()), velem)
 		return true
 	})
-	return result.Interface()
+	if err != nil {
+		return nil, err
+	}
+	return result.Interface(), nil
 }
 
 // getFieldType returns the Go type for the specified protobuf field type.
@@ -249,14 +383,30 @@ func getFieldType(field protoreflect.FieldDescriptor) reflect.Type {
 	switch {
 	case field.IsMap():
 		k := getKindType(field.MapKey().Kind())
-		v := getKindType(field.MapValue().Kind())
+		v := getElemType(field.MapValue())
+		if v == durationType {
+			// Durations out of range are kept as raw messages.
+			v = interfaceType
+		}
 		return reflect.MapOf(k, v)
 	case field.IsList():
-		t := getKindType(field.Kind())
+		t := getElemType(field)
 		return reflect.SliceOf(t)
 	default:
-		return getKindType(field.Kind())
+		return getElemType(field)
+	}
+}
+
+// getElemType returns the Go type for a single value of the specified
+// protobuf field, taking the native representations of well-known types into
+// account.
+func getElemType(field protoreflect.FieldDescriptor) reflect.Type {
+	if field.Kind() == protoreflect.MessageKind {
+		if t := getWKTType(field.Message()); t != nil {
+			return t
+		}
 	}
+	return getKindType(field.Kind())
 }
 
 // getKindType returns the Go type for the specified protobuf kind.
@@ -0,0 +1,83 @@
+package gen
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// TestMakeRawDurationMap checks that durations beyond the range of
+// time.Duration in map values are kept as raw messages.
+func TestMakeRawDurationMap(t *testing.T) {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	r, err := newRegistry([]*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(
+			durationpb.File_google_protobuf_duration_proto),
+		{
+			Name:       proto.String("acme/timeouts.proto"),
+			Package:    proto.String("acme"),
+			Dependency: []string{"google/protobuf/duration.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Timeouts"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name:     proto.String("timeouts"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".acme.Timeouts.TimeoutsEntry"),
+				}},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("TimeoutsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{{
+						Name: proto.String("key"), Number: proto.Int32(1),
+						Label: optional,
+						Type:  descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					}, {
+						Name: proto.String("value"), Number: proto.Int32(2),
+						Label:    optional,
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String(".google.protobuf.Duration"),
+					}},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newRegistry: %v", err)
+	}
+	mt, err := r.types.FindMessageByName("acme.Timeouts")
+	if err != nil {
+		t.Fatalf("find acme.Timeouts: %v", err)
+	}
+	msg := mt.New()
+	fd := msg.Descriptor().Fields().ByName("timeouts")
+	timeouts := msg.Mutable(fd).Map()
+	for key, seconds := range map[string]int64{"short": 90, "long": 1e12} {
+		d := timeouts.NewValue()
+		dmsg := d.Message()
+		dmsg.Set(dmsg.Descriptor().Fields().ByName("seconds"),
+			protoreflect.ValueOfInt64(seconds))
+		timeouts.Set(protoreflect.ValueOfString(key).MapKey(), d)
+	}
+	raw, err := r.makeRawMessage(msg)
+	if err != nil {
+		t.Fatalf("makeRawMessage: %v", err)
+	}
+	got, ok := raw["timeouts"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("timeouts = %#v, want map", raw["timeouts"])
+	}
+	if got["short"] != 90*time.Second {
+		t.Errorf("timeouts[short] = %#v, want 1m30s", got["short"])
+	}
+	long, ok := got["long"].(message)
+	if !ok || long["seconds"] != int64(1e12) {
+		t.Errorf("timeouts[long] = %#v, want raw message", got["long"])
+	}
+}
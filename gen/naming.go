@@ -0,0 +1,382 @@
+package gen
+
+import (
+	"strings"
+	"unicode"
+)
+
+// initialisms contains well-known initialisms which are conventionally
+// written in upper case in Go identifiers.
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true,
+	"DNS": true, "EOF": true, "GUID": true, "HTML": true, "HTTP": true,
+	"HTTPS": true, "ID": true, "IP": true, "JSON": true, "LHS": true,
+	"QPS": true, "RAM": true, "RHS": true, "RPC": true, "SLA": true,
+	"SMTP": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true,
+	"TTL": true, "UDP": true, "UI": true, "UID": true, "UUID": true,
+	"URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true,
+	"XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// irregularPlurals maps irregular singular nouns to their plural. Nouns
+// ending in "s" whose plural adds "es" are listed as well, since singular
+// cannot tell them from nouns ending in "se".
+var irregularPlurals = map[string]string{
+	"alias":      "aliases",
+	"bonus":      "bonuses",
+	"bus":        "buses",
+	"campus":     "campuses",
+	"canvas":     "canvases",
+	"census":     "censuses",
+	"child":      "children",
+	"crisis":     "crises",
+	"criterion":  "criteria",
+	"foot":       "feet",
+	"goose":      "geese",
+	"half":       "halves",
+	"hypothesis": "hypotheses",
+	"index":      "indices",
+	"knife":      "knives",
+	"leaf":       "leaves",
+	"life":       "lives",
+	"man":        "men",
+	"matrix":     "matrices",
+	"mouse":      "mice",
+	"ox":         "oxen",
+	"person":     "people",
+	"quiz":       "quizzes",
+	"self":       "selves",
+	"shelf":      "shelves",
+	"status":     "statuses",
+	"thesis":     "theses",
+	"tooth":      "teeth",
+	"vertex":     "vertices",
+	"virus":      "viruses",
+	"wife":       "wives",
+	"wolf":       "wolves",
+	"woman":      "women",
+}
+
+// irregularSingulars maps irregular plural nouns to their singular.
+var irregularSingulars = func() map[string]string {
+	result := make(map[string]string, len(irregularPlurals))
+	for singular, plural := range irregularPlurals {
+		result[plural] = singular
+	}
+	return result
+}()
+
+// uncountables contains nouns whose plural equals their singular.
+var uncountables = map[string]bool{
+	"data": true, "deer": true, "equipment": true, "feedback": true,
+	"fish": true, "hardware": true, "information": true, "metadata": true,
+	"money": true, "news": true, "rice": true, "series": true, "sheep": true,
+	"software": true, "species": true, "traffic": true,
+}
+
+// isASCIILower reports whether c is an ASCII lower case letter.
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+// isASCIIDigit reports whether c is an ASCII digit.
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isASCII reports whether s consists of ASCII characters only.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// upperCamel converts the specified name to upper camel case following the
+// rules of protoc-gen-go, so that, e. g., a field name is converted to the
+// corresponding Go field name.
+func upperCamel(s string) string {
+	// Invariant: if the next letter is lower case, it must be converted
+	// to upper case.
+	// That is, we process a word at a time, where words are marked by _ or
+	// upper case letter. Digits are treated as words.
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Skip over '.' in ".{{lowercase}}".
+		case c == '.':
+			b = append(b, '_') // convert '.' to '_'
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			// Convert initial '_' to ensure we start with a capital letter.
+			// Do the same for '_' after '.' to match historic behavior.
+			b = append(b, 'X') // convert '_' to 'X'
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Skip over '_' in "_{{lowercase}}".
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			// Assume we have a letter now - if not, it's a bogus identifier.
+			// The next word is a sequence of characters that must start upper case.
+			if isASCIILower(c) {
+				c -= 'a' - 'A' // convert lowercase to uppercase
+			}
+			b = append(b, c)
+			// Accept lower case sequence that follows.
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+// lowerCamel converts the specified name to lower camel case following the
+// rules protobuf uses to derive the default json_name of a field. In
+// addition, the first word is written in lower case, e. g., "FooBar" becomes
+// "fooBar" and "HTTPServer" becomes "httpServer".
+func lowerCamel(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' {
+			if i > 0 && s[i-1] == '_' && isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+		}
+	}
+	result := string(b)
+	if words := splitWords(result); len(words) > 0 &&
+		strings.HasPrefix(result, words[0]) {
+		result = strings.ToLower(words[0]) + result[len(words[0]):]
+	}
+	return result
+}
+
+// splitWords splits the specified name into words. Words are separated by
+// non-alphanumeric characters and by case changes. A run of upper case
+// letters is an acronym, which ends before an upper case letter followed by
+// a lower case letter, e. g., "HTTPServer" is split into "HTTP" and "Server".
+// As exceptions, an acronym keeps a plural "s", e. g., "IDs", and a single
+// lower case letter followed by a digit, e. g., "IPv4". Digits belong to the
+// preceding word.
+func splitWords(s string) []string {
+	var result []string
+	runes := []rune(s)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				result = append(result, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		if unicode.IsUpper(r) && (!unicode.IsUpper(prev) ||
+			i+1 < len(runes) && unicode.IsLower(runes[i+1]) &&
+				!isAcronymSuffix(runes[i+1:])) {
+			result = append(result, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		result = append(result, string(runes[start:]))
+	}
+	return result
+}
+
+// isAcronymSuffix reports whether the specified runes following the last
+// letter of an acronym start with a suffix which belongs to the acronym: a
+// plural "s" or a single lower case letter followed by a digit, as in "IDs"
+// or "IPv4".
+func isAcronymSuffix(runes []rune) bool {
+	if len(runes) == 1 || !unicode.IsLower(runes[1]) && !unicode.IsDigit(runes[1]) {
+		return runes[0] == 's'
+	}
+	return unicode.IsDigit(runes[1])
+}
+
+// joinWords joins the words of the specified name with the given separator,
+// after mapping them with the given function.
+func joinWords(s, sep string, mapping func(string) string) string {
+	words := splitWords(s)
+	for i, word := range words {
+		words[i] = mapping(word)
+	}
+	return strings.Join(words, sep)
+}
+
+// snake converts the specified name to snake case, e. g.,
+// "HTTPServerURL" to "http_server_url".
+func snake(s string) string {
+	return joinWords(s, "_", strings.ToLower)
+}
+
+// kebab converts the specified name to kebab case, e. g.,
+// "HTTPServerURL" to "http-server-url".
+func kebab(s string) string {
+	return joinWords(s, "-", strings.ToLower)
+}
+
+// screamingSnake converts the specified name to screaming snake case, e. g.,
+// "HTTPServerURL" to "HTTP_SERVER_URL".
+func screamingSnake(s string) string {
+	return joinWords(s, "_", strings.ToUpper)
+}
+
+// applyInitialisms writes the well-known initialisms within the specified
+// camel case name in upper case, e. g., "UserId" becomes "UserID".
+func applyInitialisms(s string) string {
+	words := splitWords(s)
+	if strings.Join(words, "") != s {
+		// Not a camel case name, leave it alone.
+		return s
+	}
+	for i, word := range words {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			words[i] = upper
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// matchCase converts the specified lower case word to the case of the
+// given model word.
+func matchCase(word, model string) string {
+	switch {
+	case model == "":
+		return word
+	case strings.ToUpper(model) == model && len(model) > 1:
+		return strings.ToUpper(word)
+	case unicode.IsUpper([]rune(model)[0]):
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		return string(runes)
+	default:
+		return word
+	}
+}
+
+// suffixCase converts the specified lower case suffix to the case suitable
+// for appending to the given model word.
+func suffixCase(suffix, model string) string {
+	if isUpperWord(model) {
+		return strings.ToUpper(suffix)
+	}
+	return suffix
+}
+
+// isUpperWord reports whether the specified word consists of more than one
+// letter, all in upper case.
+func isUpperWord(word string) bool {
+	return strings.ToUpper(word) == word && strings.ToLower(word) != word &&
+		len(word) > 1
+}
+
+// isAcronym reports whether the last word of the specified name is an
+// acronym, which takes a lower case plural "s", e. g., "UserIDs": it is in
+// upper case and either the name is not, or the word is a well-known
+// initialism. Otherwise, the upper case word is part of a screaming case
+// name, e. g., "USER_STATUS", whose plural is in upper case.
+func isAcronym(s, word string) bool {
+	if !isUpperWord(word) {
+		return false
+	}
+	return strings.ToUpper(s) != s || initialisms[word] && s == word
+}
+
+// commonPrefixLen returns the length of the common prefix of a and b.
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// splitLastWord splits off the last word of the specified name.
+// If the name does not end in a word, last is empty.
+func splitLastWord(s string) (prefix, last string) {
+	words := splitWords(s)
+	if len(words) == 0 || !strings.HasSuffix(s, words[len(words)-1]) {
+		return s, ""
+	}
+	last = words[len(words)-1]
+	return s[:len(s)-len(last)], last
+}
+
+// plural returns the English plural of the last word of the specified name,
+// e. g., "UserCategory" becomes "UserCategories". Names whose last word is not
+// ASCII are returned unchanged.
+func plural(s string) string {
+	prefix, word := splitLastWord(s)
+	lower := strings.ToLower(word)
+	if word == "" || !isASCII(word) || uncountables[lower] {
+		return s
+	}
+	if isAcronym(s, word) {
+		return s + "s"
+	}
+	if irregular, ok := irregularPlurals[lower]; ok {
+		return prefix + matchCase(irregular, word)
+	}
+	var result string
+	switch {
+	case strings.HasSuffix(lower, "sis"):
+		result = lower[:len(lower)-2] + "es"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"),
+		strings.HasSuffix(lower, "z"), strings.HasSuffix(lower, "ch"),
+		strings.HasSuffix(lower, "sh"):
+		result = lower + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 &&
+		!strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		result = lower[:len(lower)-1] + "ies"
+	default:
+		result = lower + "s"
+	}
+	n := commonPrefixLen(lower, result)
+	return prefix + word[:n] + suffixCase(result[n:], word)
+}
+
+// singular returns the English singular of the last word of the specified
+// name, e. g., "UserCategories" becomes "UserCategory". Names whose last word
+// is not ASCII are returned unchanged.
+func singular(s string) string {
+	prefix, word := splitLastWord(s)
+	lower := strings.ToLower(word)
+	if word == "" || !isASCII(word) || uncountables[lower] {
+		return s
+	}
+	if irregular, ok := irregularSingulars[lower]; ok {
+		return prefix + matchCase(irregular, word)
+	}
+	var n int // number of characters to strip
+	var suffix string
+	switch {
+	case isAcronym(s, word[:len(word)-1]) && strings.HasSuffix(word, "s"):
+		n = 1
+	case strings.HasSuffix(lower, "yses"):
+		n, suffix = 2, "is"
+	case strings.HasSuffix(lower, "ies") && len(lower) > 3:
+		n, suffix = 3, "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "shes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "xes"),
+		strings.HasSuffix(lower, "zes"):
+		n = 2
+	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") &&
+		!strings.HasSuffix(lower, "us") && !strings.HasSuffix(lower, "is"):
+		n = 1
+	default:
+		return s
+	}
+	return prefix + word[:len(word)-n] + suffixCase(suffix, word)
+}
@@ -0,0 +1,120 @@
+package gen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCaseConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{"upperCamel", upperCamel, "foo_bar", "FooBar"},
+		{"upperCamel", upperCamel, "_foo", "XFoo"},
+		{"upperCamel", upperCamel, "foo_bar2", "FooBar2"},
+		{"upperCamel", upperCamel, "Foo.bar", "FooBar"},
+		{"lowerCamel", lowerCamel, "foo_bar_baz", "fooBarBaz"},
+		{"lowerCamel", lowerCamel, "FooBar", "fooBar"},
+		{"lowerCamel", lowerCamel, "HTTPServer", "httpServer"},
+		{"lowerCamel", lowerCamel, "ID", "id"},
+		{"lowerCamel", lowerCamel, "fooBar", "fooBar"},
+		{"snake", snake, "HTTPServerURL", "http_server_url"},
+		{"snake", snake, "IPv4Address", "ipv4_address"},
+		{"snake", snake, "UserIDs", "user_ids"},
+		{"snake", snake, "fooBar2Baz", "foo_bar2_baz"},
+		{"snake", snake, "foo-bar baz", "foo_bar_baz"},
+		{"kebab", kebab, "HTTPServerURL", "http-server-url"},
+		{"screamingSnake", screamingSnake, "HTTPServerURL", "HTTP_SERVER_URL"},
+		{"screamingSnake", screamingSnake, "ipv4Address", "IPV4_ADDRESS"},
+		{"initialisms", applyInitialisms, "UserId", "UserID"},
+		{"initialisms", applyInitialisms, "HttpServerUrl", "HTTPServerURL"},
+		{"initialisms", applyInitialisms, "user_id", "user_id"},
+	}
+	for _, test := range tests {
+		if got := test.fn(test.in); got != test.want {
+			t.Errorf("%s(%q) = %q, want %q", test.name, test.in, got, test.want)
+		}
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"HTTPServer", []string{"HTTP", "Server"}},
+		{"fooBar", []string{"foo", "Bar"}},
+		{"foo_bar", []string{"foo", "bar"}},
+		{"IPv4Address", []string{"IPv4", "Address"}},
+		{"UserIDs", []string{"User", "IDs"}},
+		{"URLsByName", []string{"URLs", "By", "Name"}},
+		{"Base64Data", []string{"Base64", "Data"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if got := splitWords(test.in); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitWords(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		singular, plural string
+	}{
+		{"User", "Users"},
+		{"UserCategory", "UserCategories"},
+		{"Key", "Keys"},
+		{"Address", "Addresses"},
+		{"Box", "Boxes"},
+		{"Match", "Matches"},
+		{"Wish", "Wishes"},
+		{"Status", "Statuses"},
+		{"OrderStatus", "OrderStatuses"},
+		{"Bus", "Buses"},
+		{"Alias", "Aliases"},
+		{"Analysis", "Analyses"},
+		{"Quiz", "Quizzes"},
+		{"Child", "Children"},
+		{"Person", "People"},
+		{"Index", "Indices"},
+		{"UserID", "UserIDs"},
+		{"URL", "URLs"},
+		{"ServerURL", "ServerURLs"},
+		{"USER", "USERS"},
+		{"USER_STATUS", "USER_STATUSES"},
+		{"USER_ID", "USER_IDS"},
+		{"user_category", "user_categories"},
+		{"Database", "Databases"},
+		{"Case", "Cases"},
+	}
+	for _, test := range tests {
+		if got := plural(test.singular); got != test.plural {
+			t.Errorf("plural(%q) = %q, want %q", test.singular, got, test.plural)
+		}
+		if got := singular(test.plural); got != test.singular {
+			t.Errorf("singular(%q) = %q, want %q", test.plural, got, test.singular)
+		}
+	}
+}
+
+func TestPluralUnchanged(t *testing.T) {
+	for _, word := range []string{
+		"Data", "Metadata", "Series", "Sheep", "", "İİ", "UserÉtat", "Café",
+	} {
+		if got := plural(word); got != word {
+			t.Errorf("plural(%q) = %q, want %q", word, got, word)
+		}
+		if got := singular(word); got != word {
+			t.Errorf("singular(%q) = %q, want %q", word, got, word)
+		}
+	}
+	for _, word := range []string{"Class", "Status", "Analysis", "Bus"} {
+		if got := singular(word); got != word {
+			t.Errorf("singular(%q) = %q, want %q", word, got, word)
+		}
+	}
+}
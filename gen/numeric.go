@@ -0,0 +1,429 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// numberKind enumerates the representations of a number.
+type numberKind int
+
+// Number representations.
+const (
+	intNumber numberKind = iota
+	uintNumber
+	floatNumber
+)
+
+// number is a number of any Go numeric type.
+type number struct {
+	kind numberKind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+// toNumber converts the specified value to a number.
+// The boolean result reports whether the value is numeric.
+func toNumber(value interface{}) (number, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: intNumber, i: v.Int()}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return number{kind: uintNumber, u: v.Uint()}, true
+	case reflect.Float32, reflect.Float64:
+		return number{kind: floatNumber, f: v.Float()}, true
+	default:
+		return number{}, false
+	}
+}
+
+// float returns this number as a float64.
+func (n number) float() float64 {
+	switch n.kind {
+	case intNumber:
+		return float64(n.i)
+	case uintNumber:
+		return float64(n.u)
+	default:
+		return n.f
+	}
+}
+
+// compare compares this number with other by value. It returns a negative
+// number if n < other, zero if n == other, and a positive number if
+// n > other.
+func (n number) compare(other number) int {
+	switch {
+	case n.kind == floatNumber || other.kind == floatNumber:
+		a, b := n.float(), other.float()
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		default:
+			return 0
+		}
+	case n.kind == intNumber && n.i < 0:
+		if other.kind == intNumber && other.i < n.i {
+			return 1
+		}
+		if other.kind == intNumber && other.i == n.i {
+			return 0
+		}
+		return -1
+	case other.kind == intNumber && other.i < 0:
+		return 1
+	}
+	// Both numbers are non-negative now.
+	a, b := n.u, other.u
+	if n.kind == intNumber {
+		a = uint64(n.i)
+	}
+	if other.kind == intNumber {
+		b = uint64(other.i)
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// errDivisionByZero is returned for divisions and modulo operations by zero.
+var errDivisionByZero = errors.New("division by zero")
+
+// errIntegerOverflow is returned if the result of an integer operation fits
+// neither into an int64 nor into a uint64.
+var errIntegerOverflow = errors.New("integer overflow")
+
+// mustNumber converts the specified value to a number, returning an error if
+// it is not numeric.
+func mustNumber(value interface{}) (number, error) {
+	n, ok := toNumber(value)
+	if !ok {
+		return number{}, fmt.Errorf("expected number, got %T", value)
+	}
+	return n, nil
+}
+
+// int64 returns this number as an int64. Floating point numbers are
+// truncated.
+func (n number) int64() (int64, error) {
+	switch n.kind {
+	case intNumber:
+		return n.i, nil
+	case uintNumber:
+		if n.u > math.MaxInt64 {
+			return 0, errIntegerOverflow
+		}
+		return int64(n.u), nil
+	default:
+		if math.IsNaN(n.f) || n.f < math.MinInt64 || n.f >= math.MaxInt64 {
+			return 0, errIntegerOverflow
+		}
+		return int64(n.f), nil
+	}
+}
+
+// bigInt returns this integer number as a big.Int.
+func (n number) bigInt() *big.Int {
+	if n.kind == uintNumber {
+		return new(big.Int).SetUint64(n.u)
+	}
+	return big.NewInt(n.i)
+}
+
+// bigValue returns the specified integer as an int64, or as a uint64 if it is
+// not representable as int64 but as uint64.
+func bigValue(z *big.Int) (interface{}, error) {
+	switch {
+	case z.IsInt64():
+		return z.Int64(), nil
+	case z.IsUint64():
+		return z.Uint64(), nil
+	default:
+		return nil, errIntegerOverflow
+	}
+}
+
+// value returns this number as an int64, or as a float64 for floating point
+// numbers. Integers not representable as int64 are returned as uint64.
+func (n number) value() interface{} {
+	switch {
+	case n.kind == floatNumber:
+		return n.f
+	case n.kind == uintNumber && n.u > math.MaxInt64:
+		return n.u
+	default:
+		i, _ := n.int64()
+		return i
+	}
+}
+
+// arithmetic applies the specified binary operation to a and b. If either
+// number is a floating point number, fop is applied to float64 operands,
+// otherwise iop is applied to the integers, and the result is returned as
+// with bigValue.
+func arithmetic(a, b interface{}, iop func(x, y *big.Int) (*big.Int, error),
+	fop func(x, y float64) (float64, error)) (interface{}, error) {
+	na, err := mustNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	nb, err := mustNumber(b)
+	if err != nil {
+		return nil, err
+	}
+	if na.kind == floatNumber || nb.kind == floatNumber {
+		return fop(na.float(), nb.float())
+	}
+	z, err := iop(na.bigInt(), nb.bigInt())
+	if err != nil {
+		return nil, err
+	}
+	return bigValue(z)
+}
+
+// fold applies the specified binary operation to the given values from left
+// to right.
+func fold(values []interface{}, op func(a, b interface{}) (interface{}, error)) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, errors.New("missing operands")
+	}
+	n, err := mustNumber(values[0])
+	if err != nil {
+		return nil, err
+	}
+	result := n.value()
+	for _, value := range values[1:] {
+		if result, err = op(result, value); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// add2 returns a + b.
+func add2(a, b interface{}) (interface{}, error) {
+	return arithmetic(a, b, func(x, y *big.Int) (*big.Int, error) {
+		return x.Add(x, y), nil
+	}, func(x, y float64) (float64, error) {
+		return x + y, nil
+	})
+}
+
+// mul2 returns a * b.
+func mul2(a, b interface{}) (interface{}, error) {
+	return arithmetic(a, b, func(x, y *big.Int) (*big.Int, error) {
+		return x.Mul(x, y), nil
+	}, func(x, y float64) (float64, error) {
+		return x * y, nil
+	})
+}
+
+// add returns the sum of the specified numbers.
+func add(values ...interface{}) (interface{}, error) {
+	return fold(values, add2)
+}
+
+// mul returns the product of the specified numbers.
+func mul(values ...interface{}) (interface{}, error) {
+	return fold(values, mul2)
+}
+
+// sub returns a - b.
+func sub(a, b interface{}) (interface{}, error) {
+	return arithmetic(a, b, func(x, y *big.Int) (*big.Int, error) {
+		return x.Sub(x, y), nil
+	}, func(x, y float64) (float64, error) {
+		return x - y, nil
+	})
+}
+
+// div returns a / b. The division of integers is truncated.
+func div(a, b interface{}) (interface{}, error) {
+	return arithmetic(a, b, func(x, y *big.Int) (*big.Int, error) {
+		if y.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		return x.Quo(x, y), nil
+	}, func(x, y float64) (float64, error) {
+		if y == 0 {
+			return 0, errDivisionByZero
+		}
+		return x / y, nil
+	})
+}
+
+// mod returns the remainder of a / b, with the sign of a.
+func mod(a, b interface{}) (interface{}, error) {
+	return arithmetic(a, b, func(x, y *big.Int) (*big.Int, error) {
+		if y.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		return x.Rem(x, y), nil
+	}, func(x, y float64) (float64, error) {
+		if y == 0 {
+			return 0, errDivisionByZero
+		}
+		return math.Mod(x, y), nil
+	})
+}
+
+// extremum returns the first of the specified numbers for which no other
+// number compares with the given sign.
+func extremum(sign int, values []interface{}) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, errors.New("missing operands")
+	}
+	best, err := mustNumber(values[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values[1:] {
+		n, err := mustNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		if n.compare(best)*sign > 0 {
+			best = n
+		}
+	}
+	return best.value(), nil
+}
+
+// maxNumber returns the largest of the specified numbers.
+func maxNumber(values ...interface{}) (interface{}, error) {
+	return extremum(1, values)
+}
+
+// minNumber returns the smallest of the specified numbers.
+func minNumber(values ...interface{}) (interface{}, error) {
+	return extremum(-1, values)
+}
+
+// maxSeqLen is the maximum number of elements seq returns.
+const maxSeqLen = 1 << 20
+
+// seq returns a list of integers like the shell command of the same name:
+// seq last yields 1 through last, seq first last yields first through last,
+// and seq first step last yields first through last in steps of step.
+// Sequences longer than maxSeqLen are an error.
+func seq(args ...interface{}) ([]int64, error) {
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		n, err := mustNumber(arg)
+		if err != nil {
+			return nil, err
+		}
+		if bounds[i], err = n.int64(); err != nil {
+			return nil, err
+		}
+	}
+	first, step := int64(1), int64(1)
+	var last int64
+	switch len(bounds) {
+	case 1:
+		last = bounds[0]
+	case 2:
+		first, last = bounds[0], bounds[1]
+	case 3:
+		first, step, last = bounds[0], bounds[1], bounds[2]
+	default:
+		return nil, fmt.Errorf("expected 1 to 3 arguments, got %d", len(args))
+	}
+	if step == 0 {
+		return nil, errors.New("step must not be zero")
+	}
+	if step > 0 && first <= last || step < 0 && first >= last {
+		n := new(big.Int).Sub(big.NewInt(last), big.NewInt(first))
+		n.Quo(n, big.NewInt(step))
+		if n.Cmp(big.NewInt(maxSeqLen)) >= 0 {
+			return nil, fmt.Errorf("sequence exceeds %d elements", maxSeqLen)
+		}
+	}
+	var result []int64
+	for i := first; step > 0 && i <= last || step < 0 && i >= last; i += step {
+		result = append(result, i)
+		if i+step < i != (step < 0) {
+			break // overflow
+		}
+	}
+	return result, nil
+}
+
+// compare compares the specified values like sortBy does. It returns -1 if
+// a < b, 0 if a == b, and 1 if a > b.
+func compare(a, b interface{}) (int, error) {
+	cmp, err := compareValues(a, b)
+	switch {
+	case cmp < 0:
+		return -1, err
+	case cmp > 0:
+		return 1, err
+	default:
+		return 0, err
+	}
+}
+
+// toInt converts the specified number, numeric string or boolean to an
+// int64. Floating point numbers are truncated.
+func toInt(value interface{}) (int64, error) {
+	if n, ok := toNumber(value); ok {
+		return n.int64()
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		if i, err := strconv.ParseInt(v.String(), 0, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse '%s' as number: %w", v.String(), err)
+		}
+		return number{kind: floatNumber, f: f}.int64()
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to int", value)
+	}
+}
+
+// toFloat converts the specified number, numeric string or boolean to a
+// float64.
+func toFloat(value interface{}) (float64, error) {
+	if n, ok := toNumber(value); ok {
+		return n.float(), nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse '%s' as number: %w", v.String(), err)
+		}
+		return f, nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to float", value)
+	}
+}
@@ -1,10 +1,11 @@
 package gen
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -22,6 +23,30 @@ const parameterHelp = `
     Path to file template. The value can be a glob to specify multiple template
     files which define a template.
     See https://golang.org/pkg/text/template/ for template syntax.
+    Templates matched relative to the current directory are named by their
+    slash separated path relative to the leading directory of the glob
+    without pattern characters, e. g., tpl/*/main.tpl names its matches
+    a/main.tpl, b/main.tpl, etc., and tpl/*.tpl names them by their base
+    name. If the glob matches nothing there, it is matched relative to each
+    template_path directory in turn.
+
+  template_path
+    List of template directories, separated by the OS path list separator
+    (":" on Unix). Since protoc treats the first ":" in --tpl_out as the end of
+    the parameters, specify this key with --tpl_opt. Every file below a
+    template directory is available as a template named by its slash
+    separated path relative to that directory, e. g.,
+    {{ template "partials/header.tpl" . }}. If several directories contain the
+    same relative path, the first one wins.
+
+  template_layers
+    List of template layer directories, separated like template_path. The
+    files in each layer are named like those of template_path, but are parsed
+    in order after all other templates, so that a later layer redefines
+    templates (including {{ define }} blocks) of earlier layers and of the
+    main template. The glob of the template key is also matched relative to
+    the layers if it matches nothing in the template_path directories.
+    Execution errors name the layer which defined the failing template.
 
   msgopt
     Message option to use as data input. The value must use protobuf syntax to
@@ -31,16 +56,88 @@ const parameterHelp = `
 
       (fully.qualified.message.option.field).subfield1.subfield2…
 
-		Template data may contain additional fields starting with an underscore.
-		These are currently for internal use only.
+    If the option field or a subfield is repeated, the data of its elements is
+    merged. Map fields cannot be used.
+
+    Template data may contain additional fields starting with an underscore.
+    These are currently for internal use only.
+
+  extra
+    Optional file with additional data for the template. The top level of the
+    file must be a mapping whose keys do not clash with the option data.
+    The file format is determined by the file extension:
+
+      .json                                  JSON
+      .yaml, .yml                            YAML (subset, see below)
+      .textproto, .txtpb, .pbtxt, .prototxt  protobuf text format
+
+    Files with any other or no extension are read as JSON.
+
+    The YAML subset comprises block and flow mappings and sequences, plain
+    and quoted scalars, literal (|) and folded (>) block scalars, and
+    comments. Flow collections may span several lines, plain scalars may
+    not. Anchors, aliases, tags and multiple documents are not supported.
+
+  extra_format
+    Explicit format of the extra data file, overriding the file extension.
+    One of json, yaml, or textproto.
+
+  extra_type
+    Fully qualified name of the message type of a textproto extra data file.
+    The type must be defined in one of the input proto files or their
+    imports. Defaults to google.protobuf.Struct.
+
+  typemap
+    Optional file overriding and extending the built-in type maps of the
+    mapType and mapValueType template functions. The file formats are the same
+    as for the extra key. The top level maps target names to type maps, which
+    in turn map protobuf kind names (e. g., sint64), fully qualified message or
+    enum names, and the special keys enum, message, repeated, and map to
+    fmt patterns. Only the patterns of the special keys receive arguments, so
+    a literal "%" must be written as "%%" there.
+
+  var.name=value
+    Free-form template variable. Variables are available in the template
+    data under the reserved key "_vars", e. g., {{ ._vars.name }}, so the
+    name must be an identifier: a letter or underscore followed by letters,
+    digits, or underscores. By default,
+    the value is a string. An optional type hint of the form
+
+      var.name:type=value
 
-	extra=file.json
-		Optional file with JSON data to provide as additional data to the template.
+    converts the value to the given type, which must be one of string, bool,
+    int, uint, or float.
+
+  unknown_fields
+    How to handle fields in the collected option data which are unknown to
+    the schema, e. g., because an option was compiled against a newer
+    version of the option message. One of
+
+      lenient  expose unknown fields for debugging under the key "_unknown"
+               of their message as a list of Number, WireType, Value
+               triples (default)
+      strict   fail generation
+
+  header
+    Name of a template to render after the main template, with the same data
+    and global variables. Its output is prepended to that of the main
+    template, so that the header can list what the body registered, e. g.,
+    Go imports with {{ goImports }}.
+
+  go_import_path
+    Go import path of the generated file. Identifiers of this package are not
+    qualified by qualifiedGoIdent, and the package is never imported.
 
   out
     Path to output file.
 `
 
+// varsKey is the template data key for the free-form template variables.
+const varsKey = "_vars"
+
+// varPrefix is the prefix of parameter keys specifying template variables.
+const varPrefix = "var."
+
 // optionPath specifies a submessage within an option field.
 type optionPath struct {
 	// OptionFieldName is the fully qualified option field name.
@@ -105,11 +202,32 @@ type params struct {
 	// TemplatePath is the path to the input template (glob).
 	TemplatePath string
 
+	// TemplateSearchPath is the list of template directories.
+	TemplateSearchPath []string
+
+	// TemplateLayers is the list of template layer directories.
+	TemplateLayers []string
+
 	// Options specifies which option messages to use as a basis for the data.
 	Options options
 
-	// Extra optionally contains extra data for the template.
-	Extra map[string]interface{}
+	// Extra optionally describes an extra data file for the template.
+	Extra dataFile
+
+	// TypeMap optionally describes a file with type map overrides.
+	TypeMap dataFile
+
+	// UnknownFields is the mode for handling unknown fields in option data.
+	UnknownFields string
+
+	// Header is the name of the optional header template.
+	Header string
+
+	// GoImportPath is the Go import path of the generated file.
+	GoImportPath string
+
+	// Vars contains free-form template variables.
+	Vars map[string]interface{}
 
 	// OutputPath is the path to the output file.
 	OutputPath string
@@ -123,23 +241,54 @@ func (p *params) Validate() error {
 	if p.OutputPath == "" {
 		return errors.New("output path is empty")
 	}
+	switch p.UnknownFields {
+	case unknownLenient, unknownStrict:
+	default:
+		return fmt.Errorf("unsupported unknown fields mode '%s'", p.UnknownFields)
+	}
+	if err := p.Extra.Validate(); err != nil {
+		return fmt.Errorf("extra data: %w", err)
+	}
+	if err := p.TypeMap.Validate(); err != nil {
+		return fmt.Errorf("type map: %w", err)
+	}
 	return p.Options.Validate()
 }
 
 // parseParams parses the input string
 func parseParams(in string) (*params, error) {
-	var result params
+	result := params{
+		UnknownFields: unknownLenient,
+	}
 	parts := strings.Split(in, ",")
 	for _, part := range parts {
 		idx := strings.Index(part, "=")
 		if idx < 0 {
 			return nil, fmt.Errorf("invalid option '%s'", part)
 		}
+		if strings.HasPrefix(part[:idx], varPrefix) {
+			name, value, err := parseVar(part[len(varPrefix):idx], part[idx+1:])
+			if err != nil {
+				return nil, fmt.Errorf("parse variable '%s': %w", part[:idx], err)
+			}
+			if result.Vars == nil {
+				result.Vars = make(map[string]interface{})
+			}
+			if _, ok := result.Vars[name]; ok {
+				return nil, fmt.Errorf("duplicate variable '%s'", name)
+			}
+			result.Vars[name] = value
+			continue
+		}
 		switch part[:idx] {
 		default:
 			return nil, fmt.Errorf("unsupported option '%s'", part[:idx])
 		case "template":
 			result.TemplatePath = part[idx+1:]
+		case "template_path":
+			result.TemplateSearchPath = filepath.SplitList(part[idx+1:])
+		case "template_layers":
+			result.TemplateLayers = filepath.SplitList(part[idx+1:])
 		case "msgopt":
 			path, err := parseOptionPath(part[idx+1:])
 			if err != nil {
@@ -148,17 +297,19 @@ func parseParams(in string) (*params, error) {
 			}
 			result.Options.Message = path
 		case "extra":
-			filename := part[idx+1:]
-			f, err := os.Open(filename)
-			if err != nil {
-				return nil, fmt.Errorf("open extra data file '%s': %w", filename, err)
-			}
-			defer f.Close()
-			decoder := json.NewDecoder(f)
-			if err = decoder.Decode(&result.Extra); err != nil {
-				return nil, fmt.Errorf("decoding extra data file '%s': %w",
-					filename, err)
-			}
+			result.Extra.Path = part[idx+1:]
+		case "extra_format":
+			result.Extra.Format = part[idx+1:]
+		case "extra_type":
+			result.Extra.Type = protoreflect.FullName(part[idx+1:])
+		case "typemap":
+			result.TypeMap.Path = part[idx+1:]
+		case "unknown_fields":
+			result.UnknownFields = part[idx+1:]
+		case "header":
+			result.Header = part[idx+1:]
+		case "go_import_path":
+			result.GoImportPath = part[idx+1:]
 		case "out":
 			result.OutputPath = part[idx+1:]
 		}
@@ -166,6 +317,43 @@ func parseParams(in string) (*params, error) {
 	return &result, result.Validate()
 }
 
+// varNameRE matches valid template variable names, which templates can use
+// in field chains like ._vars.name.
+var varNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseVar parses the specified template variable specification of the form
+// name or name:type, with the given value.
+func parseVar(spec, value string) (name string, typed interface{}, err error) {
+	name, typ := spec, "string"
+	if idx := strings.Index(spec, ":"); idx >= 0 {
+		name, typ = spec[:idx], spec[idx+1:]
+	}
+	if name == "" {
+		return "", nil, errors.New("empty variable name")
+	}
+	if !varNameRE.MatchString(name) {
+		return "", nil, fmt.Errorf("variable name '%s' is not an identifier", name)
+	}
+	switch typ {
+	default:
+		return "", nil, fmt.Errorf("unsupported type '%s'", typ)
+	case "string":
+		typed = value
+	case "bool":
+		typed, err = strconv.ParseBool(value)
+	case "int":
+		typed, err = strconv.ParseInt(value, 0, 64)
+	case "uint":
+		typed, err = strconv.ParseUint(value, 0, 64)
+	case "float":
+		typed, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("convert value to %s: %w", typ, err)
+	}
+	return name, typed, nil
+}
+
 // parseOptionPath parses the specified input string as an option path.
 func parseOptionPath(in string) (*optionPath, error) {
 	if in == "" {
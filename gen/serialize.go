@@ -0,0 +1,376 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// jsonMember is a member of a jsonObject.
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+// jsonObject is a JSON object which keeps the order of its members.
+type jsonObject []jsonMember
+
+// MarshalJSON implements json.Marshaler.
+func (obj jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range obj {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// protoMessageOf returns the original protobuf message of the specified raw
+// message if the raw message represents nothing but that protobuf message.
+// Otherwise, e. g., if extra data has been merged into the raw message, nil
+// is returned. For an expanded google.protobuf.Any, the Any message is
+// reconstructed.
+func protoMessageOf(m message) proto.Message {
+	pm, ok := m[origMsg].(proto.Message)
+	if !ok {
+		return nil
+	}
+	md := pm.ProtoReflect().Descriptor()
+	for key := range m {
+		name := protoreflect.Name(key)
+		if !strings.HasPrefix(key, "_") && !strings.HasPrefix(key, "[") &&
+			key != anyTypeKey &&
+			md.Fields().ByName(name) == nil && md.Oneofs().ByName(name) == nil {
+			return nil
+		}
+	}
+	typeURL, ok := m[anyTypeKey].(string)
+	if !ok {
+		return pm
+	}
+	value, err := proto.MarshalOptions{Deterministic: true}.Marshal(pm)
+	if err != nil {
+		return nil
+	}
+	return &anypb.Any{TypeUrl: typeURL, Value: value}
+}
+
+// jsonValue converts the specified template value to a value which
+// encoding/json marshals as intended. Raw messages representing protobuf
+// messages, timestamps and durations are marshalled with protojson. A oneof
+// becomes an object with the members Case and Value, or null if no field is
+// set. Other maps become objects with sorted keys.
+func (r *registry) jsonValue(value interface{}) (interface{}, error) {
+	switch x := value.(type) {
+	case nil:
+		return nil, nil
+	case message:
+		if pm := protoMessageOf(x); pm != nil {
+			return r.jsonValue(pm)
+		}
+	case proto.Message:
+		data, err := protojson.MarshalOptions{Resolver: r.types}.Marshal(x)
+		if err != nil {
+			return nil, fmt.Errorf("marshal '%s' to JSON: %w",
+				x.ProtoReflect().Descriptor().FullName(), err)
+		}
+		return json.RawMessage(data), nil
+	case enumValue:
+		return string(x), nil
+	case oneofCase:
+		if x.Case == "" {
+			return nil, nil
+		}
+		elem, err := r.jsonValue(x.Value)
+		if err != nil {
+			return nil, err
+		}
+		return jsonObject{{"Case", x.Case}, {"Value", elem}}, nil
+	case []byte:
+		return x, nil
+	case time.Time:
+		return r.jsonValue(timestamppb.New(x))
+	case time.Duration:
+		return r.jsonValue(durationpb.New(x))
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		vKeys := sortedMapKeys(v)
+		result := make(jsonObject, 0, len(vKeys))
+		for _, vkey := range vKeys {
+			elem, err := r.jsonValue(v.MapIndex(vkey).Interface())
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, jsonMember{fmt.Sprint(vkey.Interface()), elem})
+		}
+		return result, nil
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, v.Len())
+		for i := range result {
+			elem, err := r.jsonValue(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			result[i] = elem
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// marshalJSON marshals the specified template value to JSON with the given
+// indentation. An empty indentation yields compact JSON.
+func (r *registry) marshalJSON(
+	value interface{}, indent string,
+) (string, error) {
+	jv, err := r.jsonValue(value)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err = enc.Encode(jv); err != nil {
+		return "", fmt.Errorf("marshal JSON: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// toJSON serialises the specified value to compact JSON. Protobuf messages
+// are serialised with the canonical protobuf JSON mapping.
+func (r *registry) toJSON(value interface{}) (string, error) {
+	return r.marshalJSON(value, "")
+}
+
+// toPrettyJSON is like toJSON but indents the JSON output.
+func (r *registry) toPrettyJSON(value interface{}) (string, error) {
+	return r.marshalJSON(value, "  ")
+}
+
+// textprotoSpaces matches the superfluous spaces prototext randomly adds after
+// field names to discourage depending on its output.
+var textprotoSpaces = regexp.MustCompile(`(?m)^(\s*[^\s:{"]+:) +`)
+
+// toTextproto serialises the specified protobuf message or raw message to
+// multi-line protobuf text format.
+func (r *registry) toTextproto(value interface{}) (string, error) {
+	var pm proto.Message
+	switch x := value.(type) {
+	case message:
+		if pm = protoMessageOf(x); pm == nil {
+			return "", errors.New("raw message does not represent a protobuf message")
+		}
+	case proto.Message:
+		pm = x
+	default:
+		return "", fmt.Errorf("expected protobuf message, got %T", value)
+	}
+	data, err := prototext.MarshalOptions{
+		Multiline: true,
+		Indent:    "  ",
+		Resolver:  r.types,
+	}.Marshal(pm)
+	if err != nil {
+		return "", fmt.Errorf("marshal '%s' to textproto: %w",
+			pm.ProtoReflect().Descriptor().FullName(), err)
+	}
+	result := textprotoSpaces.ReplaceAllString(string(data), "$1 ")
+	return strings.TrimSuffix(result, "\n"), nil
+}
+
+// decodeOrderedJSON decodes the next JSON value from the specified decoder.
+// Objects are decoded as jsonObject to keep the order of their members, and
+// numbers as json.Number.
+func decodeOrderedJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		result := jsonObject{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, jsonMember{keyTok.(string), value})
+		}
+		if _, err = dec.Token(); err != nil {
+			return nil, err
+		}
+		return result, nil
+	case json.Delim('['):
+		result := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+		if _, err = dec.Token(); err != nil {
+			return nil, err
+		}
+		return result, nil
+	default:
+		return tok, nil
+	}
+}
+
+// yamlPlain matches strings which may be written as plain YAML scalars,
+// unless they are reserved words.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/.][A-Za-z0-9_ ./-]*$`)
+
+// yamlNumeric matches strings starting like a YAML float without leading
+// digits, e. g., ".5", which the plain pattern above would accept.
+var yamlNumeric = regexp.MustCompile(`^\.[0-9]`)
+
+// yamlReserved contains plain scalars YAML parsers interpret as something
+// other than a string.
+var yamlReserved = map[string]bool{
+	"y": true, "yes": true, "n": true, "no": true, "true": true,
+	"false": true, "on": true, "off": true, "null": true, ".inf": true,
+	".nan": true,
+}
+
+// yamlScalar renders the specified decoded JSON scalar as a YAML scalar.
+func yamlScalar(value interface{}) string {
+	switch x := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if yamlPlain.MatchString(x) && !yamlNumeric.MatchString(x) &&
+			!strings.HasSuffix(x, " ") && !yamlReserved[strings.ToLower(x)] {
+			return x
+		}
+		return yamlString(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// yamlWriter writes decoded JSON values as block style YAML.
+type yamlWriter struct {
+	sb strings.Builder
+}
+
+// value writes the specified value after a key or list item indicator on the
+// current line. Nested collections are written with the given indentation
+// plus two spaces.
+func (w *yamlWriter) value(value interface{}, indent string) {
+	switch x := value.(type) {
+	case jsonObject:
+		if len(x) > 0 {
+			w.sb.WriteByte('\n')
+			w.members(x, indent+"  ", indent+"  ")
+			return
+		}
+		w.sb.WriteString(" {}\n")
+	case []interface{}:
+		if len(x) > 0 {
+			w.sb.WriteByte('\n')
+			w.items(x, indent+"  ")
+			return
+		}
+		w.sb.WriteString(" []\n")
+	default:
+		w.sb.WriteString(" " + yamlScalar(value) + "\n")
+	}
+}
+
+// members writes the members of the specified object with the given
+// indentation. The first member is written with firstIndent, which allows
+// writing an object on the line of a list item indicator.
+func (w *yamlWriter) members(obj jsonObject, firstIndent, indent string) {
+	for i, member := range obj {
+		if i == 0 {
+			w.sb.WriteString(firstIndent)
+		} else {
+			w.sb.WriteString(indent)
+		}
+		w.sb.WriteString(yamlScalar(member.key) + ":")
+		w.value(member.value, indent)
+	}
+}
+
+// items writes the items of the specified list with the given indentation.
+func (w *yamlWriter) items(list []interface{}, indent string) {
+	for _, item := range list {
+		w.sb.WriteString(indent + "-")
+		if obj, ok := item.(jsonObject); ok && len(obj) > 0 {
+			w.members(obj, " ", indent+"  ")
+		} else {
+			w.value(item, indent)
+		}
+	}
+}
+
+// toYAML serialises the specified value to block style YAML. Protobuf
+// messages are serialised like their canonical JSON representation.
+func (r *registry) toYAML(value interface{}) (string, error) {
+	data, err := r.toJSON(value)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	decoded, err := decodeOrderedJSON(dec)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("decode JSON: %w", err)
+	}
+	var w yamlWriter
+	switch x := decoded.(type) {
+	case jsonObject:
+		if len(x) > 0 {
+			w.members(x, "", "")
+			break
+		}
+		w.sb.WriteString("{}")
+	case []interface{}:
+		if len(x) > 0 {
+			w.items(x, "")
+			break
+		}
+		w.sb.WriteString("[]")
+	default:
+		w.sb.WriteString(yamlScalar(decoded))
+	}
+	return strings.TrimSuffix(w.sb.String(), "\n"), nil
+}
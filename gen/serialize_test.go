@@ -0,0 +1,63 @@
+package gen
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestYAMLScalar(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"foo", "foo"},
+		{"foo/bar.baz", "foo/bar.baz"},
+		{".hidden", ".hidden"},
+		{".5", `".5"`},
+		{".5e3", `".5e3"`},
+		{".inf", `".inf"`},
+		{".Inf", `".Inf"`},
+		{".NaN", `".NaN"`},
+		{"yes", `"yes"`},
+		{"trailing ", `"trailing "`},
+		{"1.5", `"1.5"`},
+		{nil, "null"},
+		{1.5, "1.5"},
+	}
+	for _, test := range tests {
+		if got := yamlScalar(test.in); got != test.want {
+			t.Errorf("yamlScalar(%#v) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestOneofValues(t *testing.T) {
+	r := &registry{types: new(protoregistry.Types)}
+	data := []interface{}{
+		message{"contact": oneofCase{"email", message{
+			origMsg: wrapperspb.String("a@example.com"),
+		}}},
+		message{"contact": oneofCase{}},
+	}
+	got, err := r.toJSON(data)
+	if err != nil {
+		t.Fatalf("toJSON: %v", err)
+	}
+	want := `[{"contact":{"Case":"email","Value":"a@example.com"}},` +
+		`{"contact":null}]`
+	if got != want {
+		t.Errorf("toJSON = %s, want %s", got, want)
+	}
+	cases, err := pluck("contact.Case", data)
+	if err != nil {
+		t.Fatalf("pluck: %v", err)
+	}
+	if len(cases) != 2 || cases[0] != "email" || cases[1] != "" {
+		t.Errorf("pluck = %q, want [email ]", cases)
+	}
+	if _, err := fieldOf(data[0], "contact.Other"); err == nil {
+		t.Error("fieldOf with unknown oneof field succeeded")
+	}
+}
@@ -0,0 +1,269 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// templateFile describes a template file.
+type templateFile struct {
+	// name is the template name.
+	name string
+
+	// path is the path to the template file.
+	path string
+
+	// layer is the 1-based index of the template layer the file belongs to,
+	// or 0 if the file does not belong to a layer.
+	layer int
+}
+
+// String describes this template file for error messages.
+func (tf templateFile) String() string {
+	if tf.layer == 0 {
+		return fmt.Sprintf("file '%s'", tf.path)
+	}
+	return fmt.Sprintf("file '%s' of layer %d", tf.path, tf.layer)
+}
+
+// templateSet is a set of parsed templates.
+type templateSet struct {
+	// tpl is the main template.
+	tpl *template.Template
+
+	// files maps each template name to the file which last defined it.
+	files map[string]templateFile
+}
+
+// Execute executes the main template with the specified data.
+// The template functions are bound to the specified fresh execution state.
+// If header is not empty, the named header template is executed after the
+// main template with the same data and state, and its output is written
+// before that of the main template. This way, the header can render what
+// the main template registered, e. g., Go imports.
+func (ts *templateSet) Execute(
+	w io.Writer, header string, data interface{}, state *execState,
+) error {
+	tpl, err := ts.tpl.Clone()
+	if err != nil {
+		return fmt.Errorf("clone template: %w", err)
+	}
+	state.tpl = tpl
+	tpl.Funcs(templateFuncs(state))
+	if header != "" && tpl.Lookup(header) == nil {
+		return fmt.Errorf("no header template '%s'", header)
+	}
+	var body strings.Builder
+	if err = tpl.Execute(&body, data); err != nil {
+		return ts.describeError(err)
+	}
+	if header != "" {
+		if err = tpl.ExecuteTemplate(w, header, data); err != nil {
+			return ts.describeError(err)
+		}
+	}
+	_, err = io.WriteString(w, body.String())
+	return err
+}
+
+// describeError adds the file which defined the failing template to the
+// specified execution error.
+func (ts *templateSet) describeError(err error) error {
+	var execErr template.ExecError
+	if errors.As(err, &execErr) {
+		if tf, ok := ts.files[execErr.Name]; ok {
+			return fmt.Errorf("%w (template '%s' defined in %s)",
+				err, execErr.Name, tf)
+		}
+	}
+	return err
+}
+
+// loadTemplate loads the template definition as specified by the given
+// parameters.
+func loadTemplate(p *params) (*templateSet, error) {
+	mainFiles, err := findMainTemplates(p.TemplatePath,
+		append(append([]string{}, p.TemplateSearchPath...), p.TemplateLayers...))
+	if err != nil {
+		return nil, err
+	}
+	files, err := findPartials(p.TemplateSearchPath)
+	if err != nil {
+		return nil, err
+	}
+	// Parse partials first so that the main templates take precedence,
+	// then the layers in order.
+	files = append(files, mainFiles...)
+	for i, dir := range p.TemplateLayers {
+		layerFiles, err := findDirTemplates(dir)
+		if err != nil {
+			return nil, fmt.Errorf("search template layer %d: %w", i+1, err)
+		}
+		for _, tf := range layerFiles {
+			tf.layer = i + 1
+			files = append(files, tf)
+		}
+	}
+	ts := &templateSet{
+		tpl: template.New(mainFiles[0].name).
+			Funcs(templateFuncs(&execState{})),
+		files: make(map[string]templateFile),
+	}
+	for _, tf := range files {
+		if err = ts.parseFile(tf); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
+// parseFile parses the specified template file into this template set.
+// template.ParseFiles cannot be used here: it names each template by the base
+// name of its file, whereas files from template directories are named by
+// their relative path, and it parses several files at once, so the file
+// which defined a template could not be recorded for error messages.
+func (ts *templateSet) parseFile(tf templateFile) error {
+	content, err := os.ReadFile(tf.path)
+	if err != nil {
+		return fmt.Errorf("read template %s: %w", tf, err)
+	}
+	trees := make(map[string]*parse.Tree)
+	for _, t := range ts.tpl.Templates() {
+		trees[t.Name()] = t.Tree
+	}
+	// Like template.ParseFiles, parse into tpl itself if the names match,
+	// and into a new associated template otherwise.
+	target := ts.tpl
+	if tf.name != ts.tpl.Name() {
+		target = ts.tpl.New(tf.name)
+	}
+	if _, err = target.Parse(string(content)); err != nil {
+		return fmt.Errorf("parse template %s: %w", tf, err)
+	}
+	// Record the templates (re)defined by this file.
+	for _, t := range ts.tpl.Templates() {
+		if t.Tree != nil && t.Tree != trees[t.Name()] {
+			ts.files[t.Name()] = tf
+		}
+	}
+	return nil
+}
+
+// findMainTemplates finds the main template files matching the specified
+// glob. The glob is matched relative to the current directory first, then
+// relative to the specified directories in turn.
+func findMainTemplates(glob string, dirs []string) ([]templateFile, error) {
+	// We need to execute the glob manually because the template package needs
+	// an explicit file name for template name.
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("glob: %w", err)
+	}
+	if len(files) > 0 {
+		// Name the files by their path relative to the directory the glob
+		// starts in, so that same-named files are namespaced by directory.
+		base := globBase(glob)
+		result := make([]templateFile, len(files))
+		for i, file := range files {
+			name, err := filepath.Rel(base, file)
+			if err != nil {
+				return nil, fmt.Errorf("relative template path: %w", err)
+			}
+			result[i] = templateFile{name: filepath.ToSlash(name), path: file}
+		}
+		return result, nil
+	}
+	if !filepath.IsAbs(glob) {
+		for _, dir := range dirs {
+			files, err = filepath.Glob(filepath.Join(dir, glob))
+			if err != nil {
+				return nil, fmt.Errorf("glob in '%s': %w", dir, err)
+			}
+			if len(files) == 0 {
+				continue
+			}
+			result := make([]templateFile, len(files))
+			for i, file := range files {
+				name, err := filepath.Rel(dir, file)
+				if err != nil {
+					return nil, fmt.Errorf("relative template path: %w", err)
+				}
+				result[i] = templateFile{name: filepath.ToSlash(name), path: file}
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no matching files: %s", glob)
+}
+
+// globBase returns the longest leading directory of the specified glob which
+// contains no pattern characters, e. g., "tpl" for "tpl/*/main.tpl".
+func globBase(glob string) string {
+	dir := filepath.Dir(glob)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// findPartials finds all template files in the specified template search
+// path. If several directories contain the same relative path, the first one
+// wins.
+func findPartials(searchPath []string) ([]templateFile, error) {
+	var result []templateFile
+	seen := make(map[string]bool)
+	for _, dir := range searchPath {
+		files, err := findDirTemplates(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, tf := range files {
+			if !seen[tf.name] {
+				seen[tf.name] = true
+				result = append(result, tf)
+			}
+		}
+	}
+	return result, nil
+}
+
+// findDirTemplates finds all template files below the specified directory in
+// lexical order. Each file is named by its slash separated path relative to
+// the directory. Hidden files and directories are skipped.
+func findDirTemplates(dir string) ([]templateFile, error) {
+	var result []templateFile
+	err := filepath.WalkDir(dir,
+		func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			result = append(result,
+				templateFile{name: filepath.ToSlash(name), path: path})
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search template directory '%s': %w", dir, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,405 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Special type map keys. All other keys are protobuf kind names, e. g.,
+// "sint64", or fully qualified message or enum names, e. g.,
+// "google.protobuf.Timestamp".
+const (
+	// typeMapEnum is the key for enum types. The pattern receives the local
+	// enum name with dots replaced by underscores as argument.
+	typeMapEnum = "enum"
+
+	// typeMapMessage is the key for message types. The pattern receives the
+	// local message name with dots replaced by underscores as argument.
+	typeMapMessage = "message"
+
+	// typeMapRepeated is the key for repeated fields. The pattern receives the
+	// element type as argument.
+	typeMapRepeated = "repeated"
+
+	// typeMapMap is the key for map fields. The pattern receives the key and
+	// value types as arguments.
+	typeMapMap = "map"
+)
+
+// typeMaps maps target names to type maps. A type map maps type map keys to
+// fmt patterns.
+type typeMaps map[string]map[string]string
+
+// builtinTypeMaps contains the built-in type maps.
+var builtinTypeMaps = typeMaps{
+	"go": {
+		"double":                      "float64",
+		"float":                       "float32",
+		"int32":                       "int32",
+		"int64":                       "int64",
+		"uint32":                      "uint32",
+		"uint64":                      "uint64",
+		"sint32":                      "int32",
+		"sint64":                      "int64",
+		"fixed32":                     "uint32",
+		"fixed64":                     "uint64",
+		"sfixed32":                    "int32",
+		"sfixed64":                    "int64",
+		"bool":                        "bool",
+		"string":                      "string",
+		"bytes":                       "[]byte",
+		typeMapEnum:                   "%s",
+		typeMapMessage:                "*%s",
+		typeMapRepeated:               "[]%s",
+		typeMapMap:                    "map[%s]%s",
+		"google.protobuf.Any":         "*anypb.Any",
+		"google.protobuf.Duration":    "*durationpb.Duration",
+		"google.protobuf.Empty":       "*emptypb.Empty",
+		"google.protobuf.FieldMask":   "*fieldmaskpb.FieldMask",
+		"google.protobuf.ListValue":   "*structpb.ListValue",
+		"google.protobuf.Struct":      "*structpb.Struct",
+		"google.protobuf.Timestamp":   "*timestamppb.Timestamp",
+		"google.protobuf.Value":       "*structpb.Value",
+		"google.protobuf.BoolValue":   "*wrapperspb.BoolValue",
+		"google.protobuf.BytesValue":  "*wrapperspb.BytesValue",
+		"google.protobuf.DoubleValue": "*wrapperspb.DoubleValue",
+		"google.protobuf.FloatValue":  "*wrapperspb.FloatValue",
+		"google.protobuf.Int32Value":  "*wrapperspb.Int32Value",
+		"google.protobuf.Int64Value":  "*wrapperspb.Int64Value",
+		"google.protobuf.StringValue": "*wrapperspb.StringValue",
+		"google.protobuf.UInt32Value": "*wrapperspb.UInt32Value",
+		"google.protobuf.UInt64Value": "*wrapperspb.UInt64Value",
+	},
+	"typescript": {
+		"double":                      "number",
+		"float":                       "number",
+		"int32":                       "number",
+		"int64":                       "number",
+		"uint32":                      "number",
+		"uint64":                      "number",
+		"sint32":                      "number",
+		"sint64":                      "number",
+		"fixed32":                     "number",
+		"fixed64":                     "number",
+		"sfixed32":                    "number",
+		"sfixed64":                    "number",
+		"bool":                        "boolean",
+		"string":                      "string",
+		"bytes":                       "Uint8Array",
+		typeMapEnum:                   "%s",
+		typeMapMessage:                "%s",
+		typeMapRepeated:               "%s[]",
+		typeMapMap:                    "{ [key: %s]: %s }",
+		"google.protobuf.Any":         "Any",
+		"google.protobuf.Duration":    "Duration",
+		"google.protobuf.Empty":       "Empty",
+		"google.protobuf.FieldMask":   "string[]",
+		"google.protobuf.ListValue":   "Array<any>",
+		"google.protobuf.Struct":      "{ [key: string]: any }",
+		"google.protobuf.Timestamp":   "Date",
+		"google.protobuf.Value":       "any",
+		"google.protobuf.BoolValue":   "boolean | undefined",
+		"google.protobuf.BytesValue":  "Uint8Array | undefined",
+		"google.protobuf.DoubleValue": "number | undefined",
+		"google.protobuf.FloatValue":  "number | undefined",
+		"google.protobuf.Int32Value":  "number | undefined",
+		"google.protobuf.Int64Value":  "number | undefined",
+		"google.protobuf.StringValue": "string | undefined",
+		"google.protobuf.UInt32Value": "number | undefined",
+		"google.protobuf.UInt64Value": "number | undefined",
+	},
+	"rust": {
+		"double":                      "f64",
+		"float":                       "f32",
+		"int32":                       "i32",
+		"int64":                       "i64",
+		"uint32":                      "u32",
+		"uint64":                      "u64",
+		"sint32":                      "i32",
+		"sint64":                      "i64",
+		"fixed32":                     "u32",
+		"fixed64":                     "u64",
+		"sfixed32":                    "i32",
+		"sfixed64":                    "i64",
+		"bool":                        "bool",
+		"string":                      "String",
+		"bytes":                       "Vec<u8>",
+		typeMapEnum:                   "i32",
+		typeMapMessage:                "%s",
+		typeMapRepeated:               "Vec<%s>",
+		typeMapMap:                    "::std::collections::HashMap<%s, %s>",
+		"google.protobuf.Any":         "::prost_types::Any",
+		"google.protobuf.Duration":    "::prost_types::Duration",
+		"google.protobuf.Empty":       "()",
+		"google.protobuf.FieldMask":   "::prost_types::FieldMask",
+		"google.protobuf.ListValue":   "::prost_types::ListValue",
+		"google.protobuf.Struct":      "::prost_types::Struct",
+		"google.protobuf.Timestamp":   "::prost_types::Timestamp",
+		"google.protobuf.Value":       "::prost_types::Value",
+		"google.protobuf.BoolValue":   "Option<bool>",
+		"google.protobuf.BytesValue":  "Option<Vec<u8>>",
+		"google.protobuf.DoubleValue": "Option<f64>",
+		"google.protobuf.FloatValue":  "Option<f32>",
+		"google.protobuf.Int32Value":  "Option<i32>",
+		"google.protobuf.Int64Value":  "Option<i64>",
+		"google.protobuf.StringValue": "Option<String>",
+		"google.protobuf.UInt32Value": "Option<u32>",
+		"google.protobuf.UInt64Value": "Option<u64>",
+	},
+	"sql": {
+		"double":                      "DOUBLE PRECISION",
+		"float":                       "REAL",
+		"int32":                       "INTEGER",
+		"int64":                       "BIGINT",
+		"uint32":                      "BIGINT",
+		"uint64":                      "NUMERIC(20)",
+		"sint32":                      "INTEGER",
+		"sint64":                      "BIGINT",
+		"fixed32":                     "BIGINT",
+		"fixed64":                     "NUMERIC(20)",
+		"sfixed32":                    "INTEGER",
+		"sfixed64":                    "BIGINT",
+		"bool":                        "BOOLEAN",
+		"string":                      "TEXT",
+		"bytes":                       "BYTEA",
+		typeMapEnum:                   "TEXT",
+		typeMapMessage:                "JSONB",
+		typeMapRepeated:               "%s[]",
+		typeMapMap:                    "JSONB",
+		"google.protobuf.Any":         "JSONB",
+		"google.protobuf.Duration":    "INTERVAL",
+		"google.protobuf.Empty":       "JSONB",
+		"google.protobuf.FieldMask":   "TEXT[]",
+		"google.protobuf.ListValue":   "JSONB",
+		"google.protobuf.Struct":      "JSONB",
+		"google.protobuf.Timestamp":   "TIMESTAMPTZ",
+		"google.protobuf.Value":       "JSONB",
+		"google.protobuf.BoolValue":   "BOOLEAN",
+		"google.protobuf.BytesValue":  "BYTEA",
+		"google.protobuf.DoubleValue": "DOUBLE PRECISION",
+		"google.protobuf.FloatValue":  "REAL",
+		"google.protobuf.Int32Value":  "INTEGER",
+		"google.protobuf.Int64Value":  "BIGINT",
+		"google.protobuf.StringValue": "TEXT",
+		"google.protobuf.UInt32Value": "BIGINT",
+		"google.protobuf.UInt64Value": "NUMERIC(20)",
+	},
+}
+
+// makeTypeMaps creates type maps from the built-in type maps and the
+// specified overrides, as decoded from a type map file.
+func makeTypeMaps(overrides map[string]interface{}) (typeMaps, error) {
+	result := make(typeMaps, len(builtinTypeMaps)+len(overrides))
+	for target, builtin := range builtinTypeMaps {
+		result[target] = make(map[string]string, len(builtin))
+		for key, pattern := range builtin {
+			result[target][key] = pattern
+		}
+	}
+	for target, value := range overrides {
+		override, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("type map for target '%s' is not a mapping",
+				target)
+		}
+		if result[target] == nil {
+			result[target] = make(map[string]string, len(override))
+		}
+		for key, value := range override {
+			pattern, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("type map entry '%s' for target '%s' "+
+					"is not a string", key, target)
+			}
+			result[target][key] = pattern
+		}
+	}
+	return result, nil
+}
+
+// lookup looks up the specified key in the type map for the given target and
+// applies the arguments to the pattern found. Patterns of keys without
+// arguments are taken literally, so that they may contain "%".
+func (tm typeMaps) lookup(
+	target, key string, args ...interface{},
+) (string, error) {
+	typeMap, ok := tm[target]
+	if !ok {
+		return "", fmt.Errorf("no type map for target '%s'", target)
+	}
+	pattern, ok := typeMap[key]
+	if !ok {
+		return "", fmt.Errorf("no '%s' entry in type map for target '%s'",
+			key, target)
+	}
+	if len(args) > 0 && strings.Contains(pattern, "%") {
+		return fmt.Sprintf(pattern, args...), nil
+	}
+	return pattern, nil
+}
+
+// mapNamedType maps the specified message or enum descriptor to the type
+// for the given target. A type map entry for the fully qualified name takes
+// precedence over the generic message or enum entry.
+func (tm typeMaps) mapNamedType(
+	target string, desc protoreflect.Descriptor,
+) (string, error) {
+	if pattern, ok := tm[target][string(desc.FullName())]; ok {
+		return pattern, nil
+	}
+	key := typeMapMessage
+	if _, ok := desc.(protoreflect.EnumDescriptor); ok {
+		key = typeMapEnum
+	}
+	return tm.lookup(target, key, strings.ReplaceAll(localName(desc), ".", "_"))
+}
+
+// mapKind maps the specified singular field type to the type for the given
+// target.
+func (tm typeMaps) mapKind(
+	target string, fd protoreflect.FieldDescriptor,
+) (string, error) {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return tm.mapNamedType(target, fd.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return tm.mapNamedType(target, fd.Message())
+	default:
+		return tm.lookup(target, fd.Kind().String())
+	}
+}
+
+// mapField maps the type of the specified field to the type for the given
+// target.
+func (tm typeMaps) mapField(
+	target string, fd protoreflect.FieldDescriptor,
+) (string, error) {
+	switch {
+	case fd.IsMap():
+		k, err := tm.mapKind(target, fd.MapKey())
+		if err != nil {
+			return "", err
+		}
+		v, err := tm.mapKind(target, fd.MapValue())
+		if err != nil {
+			return "", err
+		}
+		return tm.lookup(target, typeMapMap, k, v)
+	case fd.IsList():
+		elem, err := tm.mapKind(target, fd)
+		if err != nil {
+			return "", err
+		}
+		return tm.lookup(target, typeMapRepeated, elem)
+	default:
+		return tm.mapKind(target, fd)
+	}
+}
+
+// mapDescriptor maps the type of the specified field, message or enum
+// descriptor to the type for the given target.
+func (tm typeMaps) mapDescriptor(
+	target string, desc protoreflect.Descriptor,
+) (string, error) {
+	switch x := desc.(type) {
+	case protoreflect.FieldDescriptor:
+		return tm.mapField(target, x)
+	case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor:
+		return tm.mapNamedType(target, x)
+	default:
+		return "", fmt.Errorf("'%s' is not a field, message or enum",
+			desc.FullName())
+	}
+}
+
+// mapType maps the type of the specified descriptor reference (see
+// resolveDescriptor) to the type for the given target. The reference must
+// denote a field, a message or an enum.
+func (s *execState) mapType(target string, ref interface{}) (string, error) {
+	desc, err := s.registry.resolveDescriptor(ref)
+	if err != nil {
+		return "", err
+	}
+	return s.typeMaps.mapDescriptor(target, desc)
+}
+
+// mapValueType maps the type of the specified raw template data value to the
+// type for the given target. The type of lists and maps is derived from their
+// first element, in key order for maps. Raw enum values and maps not
+// representing a protobuf message do not carry their type name, so their
+// type cannot be mapped.
+func (tm typeMaps) mapValueType(
+	target string, value interface{},
+) (string, error) {
+	switch x := value.(type) {
+	case nil:
+		return "", fmt.Errorf("unable to map type of nil value")
+	case bool:
+		return tm.lookup(target, "bool")
+	case time.Time:
+		return tm.lookup(target, timestampName)
+	case time.Duration:
+		return tm.lookup(target, durationName)
+	case int32:
+		return tm.lookup(target, "int32")
+	case int64, int:
+		return tm.lookup(target, "int64")
+	case uint32:
+		return tm.lookup(target, "uint32")
+	case uint64, uint:
+		return tm.lookup(target, "uint64")
+	case float32:
+		return tm.lookup(target, "float")
+	case float64:
+		return tm.lookup(target, "double")
+	case string:
+		return tm.lookup(target, "string")
+	case []byte:
+		return tm.lookup(target, "bytes")
+	case enumValue:
+		return "", fmt.Errorf("unable to map type of enum value '%s', "+
+			"map the type of its field with mapType instead", x)
+	case message:
+		if orig, ok := x[origMsg].(proto.Message); ok {
+			return tm.mapNamedType(target, orig.ProtoReflect().Descriptor())
+		}
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return "", fmt.Errorf("unable to map element type of empty list")
+		}
+		elem, err := tm.mapValueType(target, v.Index(0).Interface())
+		if err != nil {
+			return "", err
+		}
+		return tm.lookup(target, typeMapRepeated, elem)
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "", fmt.Errorf("unable to map element type of empty map")
+		}
+		if _, ok := value.(message); ok {
+			return "", errors.New(
+				"unable to map type of map not representing a protobuf message")
+		}
+		vkey := sortedMapKeys(v)[0]
+		k, err := tm.mapValueType(target, vkey.Interface())
+		if err != nil {
+			return "", err
+		}
+		elem, err := tm.mapValueType(target, v.MapIndex(vkey).Interface())
+		if err != nil {
+			return "", err
+		}
+		return tm.lookup(target, typeMapMap, k, elem)
+	default:
+		return "", fmt.Errorf("unable to map type %T", value)
+	}
+}
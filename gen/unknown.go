@@ -0,0 +1,139 @@
+package gen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// unknownKey is the message key to the decoded unknown fields of a message.
+const unknownKey = "_unknown"
+
+// Modes for handling unknown fields in option data.
+const (
+	// unknownLenient exposes unknown fields under unknownKey.
+	unknownLenient = "lenient"
+
+	// unknownStrict fails generation if option data contains unknown fields.
+	unknownStrict = "strict"
+)
+
+// unknownField describes a field unknown to the schema of its message.
+type unknownField struct {
+	// Number is the field number.
+	Number int32
+
+	// WireType is the name of the wire type: varint, fixed32, fixed64,
+	// bytes, or group.
+	WireType string
+
+	// Value is the raw value: a uint64 for varint and fixed64 fields, a
+	// uint32 for fixed32 fields, a []byte for bytes fields, and a
+	// []unknownField for groups.
+	Value interface{}
+}
+
+// decodeUnknown decodes the specified raw unknown fields.
+func decodeUnknown(raw []byte) ([]unknownField, error) {
+	var result []unknownField
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			return nil, fmt.Errorf("parse tag: %w", protowire.ParseError(n))
+		}
+		raw = raw[n:]
+		field := unknownField{Number: int32(num)}
+		switch typ {
+		case protowire.VarintType:
+			field.WireType = "varint"
+			field.Value, n = protowire.ConsumeVarint(raw)
+		case protowire.Fixed32Type:
+			field.WireType = "fixed32"
+			field.Value, n = protowire.ConsumeFixed32(raw)
+		case protowire.Fixed64Type:
+			field.WireType = "fixed64"
+			field.Value, n = protowire.ConsumeFixed64(raw)
+		case protowire.BytesType:
+			field.WireType = "bytes"
+			field.Value, n = protowire.ConsumeBytes(raw)
+		case protowire.StartGroupType:
+			field.WireType = "group"
+			var group []byte
+			if group, n = protowire.ConsumeGroup(num, raw); n >= 0 {
+				var err error
+				if field.Value, err = decodeUnknown(group); err != nil {
+					return nil, fmt.Errorf("field %d: %w", num, err)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("field %d: unsupported wire type %d", num, typ)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		raw = raw[n:]
+		result = append(result, field)
+	}
+	return result, nil
+}
+
+// checkUnknown returns an error if the specified message or one of its
+// submessages contains unknown fields.
+func checkUnknown(m protoreflect.Message) error {
+	if raw := m.GetUnknown(); len(raw) > 0 {
+		fields, err := decodeUnknown(raw)
+		if err != nil {
+			return fmt.Errorf("message '%s': decode unknown fields: %w",
+				m.Descriptor().FullName(), err)
+		}
+		numbers := make([]string, len(fields))
+		for i, field := range fields {
+			numbers[i] = fmt.Sprint(field.Number)
+		}
+		return fmt.Errorf("message '%s' has unknown fields with numbers %s",
+			m.Descriptor().FullName(), strings.Join(numbers, ", "))
+	}
+	var err error
+	var fds []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fds = append(fds, fd)
+		return true
+	})
+	sort.Slice(fds, func(i, j int) bool {
+		return fds[i].Number() < fds[j].Number()
+	})
+	for _, fd := range fds {
+		v := m.Get(fd)
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				continue
+			}
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				if err = checkUnknown(v.Message()); err != nil {
+					err = fmt.Errorf("key '%v': %w", k.Interface(), err)
+					return false
+				}
+				return true
+			})
+		case fd.Message() == nil:
+			continue
+		case fd.IsList():
+			for i := 0; i != v.List().Len(); i++ {
+				if err = checkUnknown(v.List().Get(i).Message()); err != nil {
+					err = fmt.Errorf("element %d: %w", i, err)
+					break
+				}
+			}
+		default:
+			err = checkUnknown(v.Message())
+		}
+		if err != nil {
+			return fmt.Errorf("field '%s': %w", fd.Name(), err)
+		}
+	}
+	return nil
+}
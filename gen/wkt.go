@@ -0,0 +1,180 @@
+package gen
+
+import (
+	"math"
+	"reflect"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Fully qualified names of the well-known types with a native representation
+// in template data.
+const (
+	timestampName = "google.protobuf.Timestamp"
+	durationName  = "google.protobuf.Duration"
+	structName    = "google.protobuf.Struct"
+	valueName     = "google.protobuf.Value"
+	listValueName = "google.protobuf.ListValue"
+	fieldMaskName = "google.protobuf.FieldMask"
+	anyName       = "google.protobuf.Any"
+)
+
+// wrapperNames contains the fully qualified names of the wrapper types.
+var wrapperNames = map[protoreflect.FullName]bool{
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+// Go types of the native representations of well-known types.
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	jsonMapType   = reflect.TypeOf(map[string]interface{}{})
+	jsonListType  = reflect.TypeOf([]interface{}{})
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	pathsType     = reflect.TypeOf([]string{})
+)
+
+// getWKTType returns the Go type of the native representation of the
+// specified message type, or nil if the message type is not a well-known type
+// with a native representation.
+func getWKTType(md protoreflect.MessageDescriptor) reflect.Type {
+	switch name := md.FullName(); {
+	case name == timestampName:
+		return timeType
+	case name == durationName:
+		return durationType
+	case name == structName:
+		return jsonMapType
+	case name == valueName, wrapperNames[name]:
+		return interfaceType
+	case name == listValueName:
+		return jsonListType
+	case name == fieldMaskName:
+		return pathsType
+	default:
+		return nil
+	}
+}
+
+// wktField returns the value of the named field of the specified message.
+func wktField(m protoreflect.Message, name protoreflect.Name) protoreflect.Value {
+	return m.Get(m.Descriptor().Fields().ByName(name))
+}
+
+// makeDuration converts the specified seconds and nanoseconds to a
+// time.Duration. If the duration is out of range, ok is false.
+func makeDuration(seconds, nanos int64) (value interface{}, ok bool) {
+	const maxSeconds = math.MaxInt64 / int64(time.Second)
+	if seconds > maxSeconds || seconds < -maxSeconds {
+		return nil, false
+	}
+	d := time.Duration(seconds) * time.Second
+	if nanos > 0 && d > math.MaxInt64-time.Duration(nanos) ||
+		nanos < 0 && d < math.MinInt64-time.Duration(nanos) {
+		return nil, false
+	}
+	return d + time.Duration(nanos), true
+}
+
+// makeWKTValue converts the specified message to its native representation if
+// it is a well-known type with a native representation. Otherwise, ok is
+// false.
+func makeWKTValue(m protoreflect.Message) (value interface{}, ok bool) {
+	md := m.Descriptor()
+	switch name := md.FullName(); {
+	case name == timestampName:
+		return time.Unix(wktField(m, "seconds").Int(),
+			wktField(m, "nanos").Int()).UTC(), true
+	case name == durationName:
+		return makeDuration(wktField(m, "seconds").Int(),
+			wktField(m, "nanos").Int())
+	case name == structName:
+		result := make(map[string]interface{})
+		wktField(m, "fields").Map().Range(
+			func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				result[k.String()], _ = makeWKTValue(v.Message())
+				return true
+			})
+		return result, true
+	case name == valueName:
+		od := md.Oneofs().ByName("kind")
+		fd := m.WhichOneof(od)
+		if fd == nil {
+			return nil, true
+		}
+		v := m.Get(fd)
+		switch fd.Kind() {
+		case protoreflect.EnumKind: // null_value
+			return nil, true
+		case protoreflect.MessageKind:
+			return makeWKTValue(v.Message())
+		default:
+			return v.Interface(), true
+		}
+	case name == listValueName:
+		list := wktField(m, "values").List()
+		result := make([]interface{}, list.Len())
+		for i := range result {
+			result[i], _ = makeWKTValue(list.Get(i).Message())
+		}
+		return result, true
+	case wrapperNames[name]:
+		return wktField(m, "value").Interface(), true
+	case name == fieldMaskName:
+		list := wktField(m, "paths").List()
+		result := make([]string, list.Len())
+		for i := range result {
+			result[i] = list.Get(i).String()
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// timeLayouts maps the names of predefined layouts to the layouts.
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+// formatTime formats the specified timestamp with the given layout, which is
+// either a Go time layout or the name of a predefined layout, e. g.,
+// "RFC3339".
+func formatTime(layout string, t time.Time) string {
+	if predefined, ok := timeLayouts[layout]; ok {
+		layout = predefined
+	}
+	return t.Format(layout)
+}
+
+// unix returns the specified timestamp as seconds since the Unix epoch.
+func unix(t time.Time) int64 {
+	return t.Unix()
+}
+
+// seconds returns the specified duration in seconds.
+func seconds(d time.Duration) float64 {
+	return d.Seconds()
+}
@@ -0,0 +1,747 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// yamlLine describes a significant (non-empty, non-comment) line of YAML
+// input.
+type yamlLine struct {
+	// num is the 1-based line number.
+	num int
+
+	// indent is the number of leading spaces.
+	indent int
+
+	// parent is the indentation of the parent node. The content of a block
+	// scalar starting on this line must be indented by more spaces. It
+	// differs from indent for the content of a sequence item.
+	parent int
+
+	// text is the line content without indentation and trailing comment.
+	text string
+
+	// tab reports whether the indentation is followed by a tab. This is only
+	// allowed within block scalars, which are parsed from the raw lines.
+	tab bool
+}
+
+// yamlParser parses a subset of YAML: block and flow mappings and sequences,
+// plain and quoted scalars, literal and folded block scalars, and comments.
+type yamlParser struct {
+	// raw contains the raw input lines.
+	raw []string
+
+	// lines contains the significant input lines.
+	lines []yamlLine
+
+	// pos is the index of the current line in lines.
+	pos int
+}
+
+// decodeYAML decodes the specified YAML document. Mappings are decoded as
+// map[string]interface{}, sequences as []interface{}, and scalars as nil,
+// bool, int64, float64, or string.
+func decodeYAML(data []byte) (interface{}, error) {
+	// The line break of the last line does not start another line.
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"),
+		"\n")
+	p := &yamlParser{
+		raw: strings.Split(text, "\n"),
+	}
+	if err := p.scan(); err != nil {
+		return nil, err
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	value, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		if err := p.checkTab(p.lines[p.pos]); err != nil {
+			return nil, err
+		}
+		return nil, p.errorf(p.lines[p.pos].num, "unexpected content")
+	}
+	return value, nil
+}
+
+// errorf returns an error at the specified line.
+func (p *yamlParser) errorf(line int, format string, args ...interface{}) error {
+	return &lineError{line, fmt.Errorf(format, args...)}
+}
+
+// scan splits the raw input into significant lines.
+func (p *yamlParser) scan() error {
+	for i, raw := range p.raw {
+		text := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(text)
+		tab := strings.HasPrefix(text, "\t")
+		text = strings.TrimRight(stripYAMLComment(text), " \t")
+		switch {
+		case text == "":
+			continue
+		case indent == 0 && text == "---":
+			if len(p.lines) > 0 {
+				return p.errorf(i+1, "multiple documents are not supported")
+			}
+			continue
+		case indent == 0 && text == "...":
+			return nil
+		case indent == 0 && strings.HasPrefix(text, "%"):
+			return p.errorf(i+1, "directives are not supported")
+		}
+		p.lines = append(p.lines, yamlLine{
+			num:    i + 1,
+			indent: indent,
+			parent: indent,
+			text:   text,
+			tab:    tab,
+		})
+	}
+	return nil
+}
+
+// checkTab returns an error if the specified line, which is not part of a
+// block scalar, has a tab in its indentation.
+func (p *yamlParser) checkTab(line yamlLine) error {
+	if line.tab {
+		return p.errorf(line.num, "tab in indentation")
+	}
+	return nil
+}
+
+// stripYAMLComment removes a trailing comment from the specified text.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" \t[{,:-?", text[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// isSeqItem reports whether the specified line text starts a sequence item.
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseNode parses the node starting at the current line, which must be
+// indented by at least minIndent spaces.
+func (p *yamlParser) parseNode(minIndent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent < minIndent {
+		return nil, nil
+	}
+	line := p.lines[p.pos]
+	if err := p.checkTab(line); err != nil {
+		return nil, err
+	}
+	if isSeqItem(line.text) {
+		return p.parseSeq(line.indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMap(line.indent)
+	}
+	p.pos++
+	value, err := p.parseValue(line, line.text, line.parent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > line.indent {
+		return nil, p.errorf(p.lines[p.pos].num,
+			"multi-line plain scalars are not supported")
+	}
+	return value, nil
+}
+
+// parseSeq parses a block sequence at the specified indentation.
+func (p *yamlParser) parseSeq(indent int) (interface{}, error) {
+	result := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if err := p.checkTab(line); err != nil {
+			return nil, err
+		}
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line.num, "unexpected indentation")
+		}
+		if !isSeqItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.parseNode(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+			continue
+		}
+		// Treat the item content as a line of its own, indented to its column.
+		p.lines[p.pos] = yamlLine{
+			num:    line.num,
+			indent: line.indent + len(line.text) - len(rest),
+			parent: indent,
+			text:   rest,
+		}
+		item, err := p.parseNode(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// parseMap parses a block mapping at the specified indentation.
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	result := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if err := p.checkTab(line); err != nil {
+			return nil, err
+		}
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line.num, "unexpected indentation")
+		}
+		if isSeqItem(line.text) {
+			break
+		}
+		rawKey, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, p.errorf(line.num, "expected mapping key")
+		}
+		key, err := parseYAMLKey(rawKey)
+		if err != nil {
+			return nil, p.errorf(line.num, "%s", err)
+		}
+		if _, ok := result[key]; ok {
+			return nil, p.errorf(line.num, "duplicate key '%s'", key)
+		}
+		p.pos++
+		var value interface{}
+		switch {
+		case rest != "":
+			value, err = p.parseValue(line, rest, indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent &&
+			isSeqItem(p.lines[p.pos].text):
+			value, err = p.parseSeq(indent)
+		default:
+			value, err = p.parseNode(indent + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// parseValue parses the specified inline value text found on the given line
+// within a parent node with the specified indentation. The current line must
+// already have been consumed.
+func (p *yamlParser) parseValue(
+	line yamlLine, text string, parent int,
+) (interface{}, error) {
+	switch text[0] {
+	case '|', '>':
+		return p.parseBlockScalar(line, text, parent)
+	case '[', '{':
+		// Flow collections may span several lines.
+		for flowDepth(text) > 0 && p.pos < len(p.lines) {
+			if err := p.checkTab(p.lines[p.pos]); err != nil {
+				return nil, err
+			}
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+		fp := &yamlFlowParser{text: text}
+		value, err := fp.parse()
+		if err != nil {
+			return nil, p.errorf(line.num, "%s", err)
+		}
+		return value, nil
+	case '&', '*', '!':
+		return nil, p.errorf(line.num, "anchors, aliases and tags are not supported")
+	}
+	value, err := parseYAMLScalar(text)
+	if err != nil {
+		return nil, p.errorf(line.num, "%s", err)
+	}
+	return value, nil
+}
+
+// parseBlockScalar parses a literal or folded block scalar whose header is
+// found on the specified line, within a parent node with the specified
+// indentation.
+func (p *yamlParser) parseBlockScalar(
+	line yamlLine, header string, parent int,
+) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	for _, c := range []byte(header[1:]) {
+		switch c {
+		case '-', '+':
+			chomp = c
+		default:
+			return nil, p.errorf(line.num, "unsupported block scalar header '%s'",
+				header)
+		}
+	}
+	// Collect raw lines which are blank or more indented than the parent.
+	var body []string
+	blockIndent := -1
+	end := line.num
+	for i := line.num; i < len(p.raw); i++ {
+		raw := p.raw[i]
+		text := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(text)
+		if text == "" {
+			body = append(body, "")
+			continue
+		}
+		if indent <= parent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = indent
+		}
+		if indent < blockIndent {
+			return nil, p.errorf(i+1, "bad indentation in block scalar")
+		}
+		body = append(body, raw[blockIndent:])
+		end = i + 1
+	}
+	trailing := countTrailingEmpty(body)
+	body = body[:len(body)-trailing]
+	// Skip the significant lines consumed by the block scalar.
+	for p.pos < len(p.lines) && p.lines[p.pos].num <= end {
+		p.pos++
+	}
+	var sb strings.Builder
+	for i, text := range body {
+		if i > 0 {
+			// Folding turns single line breaks between regular lines into spaces.
+			// A line break followed by blank lines is dropped instead. Lines
+			// starting with white space, including tabs, are not folded.
+			switch {
+			case !folded || text == "" ||
+				isYAMLSpaced(text) || isYAMLSpaced(body[i-1]):
+				sb.WriteByte('\n')
+			case body[i-1] == "":
+			default:
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(text)
+	}
+	result := sb.String()
+	switch {
+	case result == "":
+	case chomp == '-':
+	case chomp == '+':
+		result += strings.Repeat("\n", 1+trailing)
+	default:
+		result += "\n"
+	}
+	return result, nil
+}
+
+// isYAMLSpaced reports whether the specified block scalar line starts with
+// white space.
+func isYAMLSpaced(text string) bool {
+	return strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")
+}
+
+// flowDepth returns the nesting depth of flow collections at the end of the
+// specified text, ignoring brackets in quoted scalars.
+func flowDepth(text string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// countTrailingEmpty counts the trailing empty strings in the specified slice.
+func countTrailingEmpty(strs []string) int {
+	n := 0
+	for n < len(strs) && strs[len(strs)-1-n] == "" {
+		n++
+	}
+	return n
+}
+
+// splitYAMLKey splits the specified line text into a mapping key and the
+// remaining value text. If the text is not a mapping entry, ok is false.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			return strings.TrimRight(text[:i], " "),
+				strings.TrimLeft(text[i+1:], " "), true
+		}
+	}
+	return "", "", false
+}
+
+// parseYAMLKey parses the specified raw mapping key.
+func parseYAMLKey(raw string) (string, error) {
+	if raw == "" {
+		return "", errors.New("empty mapping key")
+	}
+	switch raw[0] {
+	case '"', '\'':
+		value, rest, err := parseYAMLQuoted(raw)
+		if err != nil {
+			return "", err
+		}
+		if rest != "" {
+			return "", fmt.Errorf("unexpected '%s' after quoted key", rest)
+		}
+		return value, nil
+	case '[', '{', '&', '*', '!', '?':
+		return "", fmt.Errorf("unsupported mapping key '%s'", raw)
+	}
+	return raw, nil
+}
+
+// parseYAMLScalar parses the specified scalar text.
+func parseYAMLScalar(text string) (interface{}, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		value, rest, err := parseYAMLQuoted(text)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("unexpected '%s' after quoted scalar", rest)
+		}
+		return value, nil
+	}
+	if strings.Contains(text, ": ") || strings.HasSuffix(text, ":") {
+		return nil, fmt.Errorf("mapping value not allowed in plain scalar '%s'",
+			text)
+	}
+	return resolveYAMLPlain(text), nil
+}
+
+// parseYAMLQuoted parses the single- or double-quoted scalar at the start of
+// the specified text. It returns the scalar value and the remaining text
+// without leading spaces.
+func parseYAMLQuoted(text string) (value, rest string, err error) {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) &&
+			text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			rest = strings.TrimLeft(text[i+1:], " ")
+			if quote == '\'' {
+				return strings.ReplaceAll(text[1:i], "''", "'"), rest, nil
+			}
+			value, err = unescapeYAMLDouble(text[1:i])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid double-quoted scalar %s: %w",
+					text[:i+1], err)
+			}
+			return value, rest, nil
+		}
+	}
+	return "", "", errors.New("unterminated quoted scalar")
+}
+
+// yamlEscapes maps the single character escape sequences of double-quoted
+// YAML scalars to their values.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// yamlHexEscapes maps the hexadecimal escape sequence characters of
+// double-quoted YAML scalars to the number of hexadecimal digits.
+var yamlHexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+// unescapeYAMLDouble resolves the escape sequences in the specified body of a
+// double-quoted YAML scalar.
+func unescapeYAMLDouble(body string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		if i++; i == len(body) {
+			return "", errors.New("incomplete escape sequence")
+		}
+		if value, ok := yamlEscapes[body[i]]; ok {
+			sb.WriteString(value)
+			continue
+		}
+		digits, ok := yamlHexEscapes[body[i]]
+		if !ok {
+			return "", fmt.Errorf("unknown escape sequence '\\%c'", body[i])
+		}
+		if i+digits >= len(body) {
+			return "", fmt.Errorf("incomplete escape sequence '\\%s'", body[i:])
+		}
+		r, err := strconv.ParseUint(body[i+1:i+1+digits], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence '\\%s'",
+				body[i:i+1+digits])
+		}
+		sb.WriteRune(rune(r))
+		i += digits
+	}
+	return sb.String(), nil
+}
+
+// resolveYAMLPlain resolves the specified plain scalar to a typed value.
+func resolveYAMLPlain(text string) interface{} {
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	digits := strings.TrimLeft(text, "+-")
+	switch {
+	case strings.HasPrefix(digits, "0x"), strings.HasPrefix(digits, "0o"):
+		if i, err := strconv.ParseInt(text, 0, 64); err == nil {
+			return i
+		}
+	default:
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i
+		}
+	}
+	if strings.ContainsAny(digits, "0123456789") &&
+		strings.Trim(digits, "0123456789.eE+-") == "" {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	return text
+}
+
+// yamlFlowParser parses a YAML flow collection.
+type yamlFlowParser struct {
+	// text is the input text.
+	text string
+
+	// pos is the current position within text.
+	pos int
+}
+
+// parse parses the complete input text as a flow node.
+func (fp *yamlFlowParser) parse() (interface{}, error) {
+	value, err := fp.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	fp.skipSpace()
+	if fp.pos < len(fp.text) {
+		return nil, fmt.Errorf("unexpected '%s' after flow collection",
+			fp.text[fp.pos:])
+	}
+	return value, nil
+}
+
+// skipSpace skips whitespace.
+func (fp *yamlFlowParser) skipSpace() {
+	for fp.pos < len(fp.text) && fp.text[fp.pos] == ' ' {
+		fp.pos++
+	}
+}
+
+// parseNode parses a flow node.
+func (fp *yamlFlowParser) parseNode() (interface{}, error) {
+	fp.skipSpace()
+	if fp.pos == len(fp.text) {
+		return nil, errors.New("unexpected end of flow collection")
+	}
+	switch fp.text[fp.pos] {
+	case '[':
+		return fp.parseSeq()
+	case '{':
+		return fp.parseMap()
+	case '"', '\'':
+		value, rest, err := parseYAMLQuoted(fp.text[fp.pos:])
+		if err != nil {
+			return nil, err
+		}
+		fp.pos = len(fp.text) - len(rest)
+		return value, nil
+	}
+	return resolveYAMLPlain(fp.plain()), nil
+}
+
+// plain consumes a plain scalar.
+func (fp *yamlFlowParser) plain() string {
+	start := fp.pos
+	for ; fp.pos < len(fp.text); fp.pos++ {
+		c := fp.text[fp.pos]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if c == ':' && (fp.pos+1 == len(fp.text) ||
+			strings.IndexByte(" ,]}", fp.text[fp.pos+1]) >= 0) {
+			break
+		}
+	}
+	return strings.TrimRight(fp.text[start:fp.pos], " ")
+}
+
+// parseSeq parses a flow sequence.
+func (fp *yamlFlowParser) parseSeq() (interface{}, error) {
+	fp.pos++ // '['
+	result := []interface{}{}
+	for {
+		fp.skipSpace()
+		if fp.pos < len(fp.text) && fp.text[fp.pos] == ']' {
+			fp.pos++
+			return result, nil
+		}
+		item, err := fp.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+		if err = fp.parseSeparator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseMap parses a flow mapping.
+func (fp *yamlFlowParser) parseMap() (interface{}, error) {
+	fp.pos++ // '{'
+	result := make(map[string]interface{})
+	for {
+		fp.skipSpace()
+		if fp.pos < len(fp.text) && fp.text[fp.pos] == '}' {
+			fp.pos++
+			return result, nil
+		}
+		var key string
+		if fp.pos < len(fp.text) &&
+			(fp.text[fp.pos] == '"' || fp.text[fp.pos] == '\'') {
+			value, rest, err := parseYAMLQuoted(fp.text[fp.pos:])
+			if err != nil {
+				return nil, err
+			}
+			key = value
+			fp.pos = len(fp.text) - len(rest)
+		} else {
+			key = fp.plain()
+		}
+		if key == "" {
+			return nil, errors.New("empty mapping key in flow mapping")
+		}
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("duplicate key '%s'", key)
+		}
+		fp.skipSpace()
+		var value interface{}
+		if fp.pos < len(fp.text) && fp.text[fp.pos] == ':' {
+			fp.pos++
+			fp.skipSpace()
+			if fp.pos < len(fp.text) && fp.text[fp.pos] != ',' &&
+				fp.text[fp.pos] != '}' {
+				var err error
+				if value, err = fp.parseNode(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		result[key] = value
+		if err := fp.parseSeparator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseSeparator consumes the separator after a flow collection entry. If the
+// separator is the specified closing character, it is not consumed.
+func (fp *yamlFlowParser) parseSeparator(closing byte) error {
+	fp.skipSpace()
+	if fp.pos == len(fp.text) {
+		return fmt.Errorf("missing '%c' in flow collection", closing)
+	}
+	switch fp.text[fp.pos] {
+	case ',':
+		fp.pos++
+		return nil
+	case closing:
+		return nil
+	default:
+		return fmt.Errorf("unexpected '%c' in flow collection", fp.text[fp.pos])
+	}
+}
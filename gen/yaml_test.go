@@ -0,0 +1,104 @@
+package gen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+	}{
+		{"empty", "", nil},
+		{"comment only", "# nothing\n", nil},
+		{"scalars", "s: foo\ni: 42\nh: 0x1f\nf: 1.5\nb: true\nn: ~\n",
+			map[string]interface{}{
+				"s": "foo", "i": int64(42), "h": int64(31), "f": 1.5, "b": true,
+				"n": nil,
+			}},
+		{"url value", "url: http://example.com\n",
+			map[string]interface{}{"url": "http://example.com"}},
+		{"nested mapping", "a:\n  b: c\n",
+			map[string]interface{}{"a": map[string]interface{}{"b": "c"}}},
+		{"sequence under key", "a:\n- x\n- y\n",
+			map[string]interface{}{"a": []interface{}{"x", "y"}}},
+		{"mapping in sequence", "- a: 1\n  b: 2\n- c\n",
+			[]interface{}{
+				map[string]interface{}{"a": int64(1), "b": int64(2)}, "c",
+			}},
+		{"comments", "a: 'x # y' # comment\n",
+			map[string]interface{}{"a": "x # y"}},
+		{"single quoted", "a: 'it''s'\n", map[string]interface{}{"a": "it's"}},
+		{"double quoted escapes", `a: "\/\x41\u00e9\t\N\"\\"` + "\n",
+			map[string]interface{}{"a": "/Aé\t\u0085\"\\"}},
+		{"literal", "a: |\n  x\n   y\n",
+			map[string]interface{}{"a": "x\n y\n"}},
+		{"literal strip", "a: |-\n  x\n", map[string]interface{}{"a": "x"}},
+		{"literal keep", "a: |+\n  x\n", map[string]interface{}{"a": "x\n"}},
+		{"literal keep blank lines", "a: |+\n  x\n\n",
+			map[string]interface{}{"a": "x\n\n"}},
+		{"folded", "a: >\n  x\n  y\n\n  z\n",
+			map[string]interface{}{"a": "x y\nz\n"}},
+		{"literal in sequence", "- |\n  foo\n", []interface{}{"foo\n"}},
+		{"literal in sequence under key", "a:\n- |\n  foo\n- bar\n",
+			map[string]interface{}{"a": []interface{}{"foo\n", "bar"}}},
+		{"literal in mapping in sequence", "- a: |\n    foo\n  b: 1\n",
+			[]interface{}{map[string]interface{}{"a": "foo\n", "b": int64(1)}}},
+		{"flow", "a: [1, 'b', {c: d}]\n",
+			map[string]interface{}{"a": []interface{}{
+				int64(1), "b", map[string]interface{}{"c": "d"},
+			}}},
+		{"multi-line flow", "a: [1,\n  2, {b: c,\n  d: e}]\nf: g\n",
+			map[string]interface{}{
+				"a": []interface{}{
+					int64(1), int64(2), map[string]interface{}{"b": "c", "d": "e"},
+				},
+				"f": "g",
+			}},
+		{"tabs in literal", "a: |\n  \tx\n  y\t\n",
+			map[string]interface{}{"a": "\tx\ny\t\n"}},
+		{"tabs in folded", "- >-\n  \tx\n  \ty\n",
+			[]interface{}{"\tx\n\ty"}},
+		{"multi-line flow in sequence", "- {a: 1,\n   b: 2}\n",
+			[]interface{}{map[string]interface{}{"a": int64(1), "b": int64(2)}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeYAML([]byte(test.input))
+			if err != nil {
+				t.Fatalf("decodeYAML(%q): %v", test.input, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeYAML(%q) = %#v, want %#v", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"nested mapping value", "a: b: c\n"},
+		{"tab indentation", "a:\n\tb: c\n"},
+		{"tab after indentation", "a:\n  \tb: c\n"},
+		{"tab in sequence", "- a\n\t- b\n"},
+		{"duplicate key", "a: 1\na: 2\n"},
+		{"unknown escape", `a: "\q"` + "\n"},
+		{"incomplete escape", `a: "\u00"` + "\n"},
+		{"unterminated flow", "a: [1, 2\n"},
+		{"anchor", "a: &x 1\n"},
+		{"multiple documents", "a: 1\n---\nb: 2\n"},
+		{"unexpected indentation", "a: 1\n  b: 2\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, err := decodeYAML([]byte(test.input)); err == nil {
+				t.Errorf("decodeYAML(%q) = %#v, want error", test.input, got)
+			}
+		})
+	}
+}